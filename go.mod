@@ -3,9 +3,11 @@ module github.com/pausan/syncdbdocs
 go 1.15
 
 require (
+	github.com/ClickHouse/clickhouse-go v1.5.4
 	github.com/denisenkom/go-mssqldb v0.10.0
 	github.com/georgysavva/scany v0.2.9
 	github.com/go-sql-driver/mysql v1.6.0
 	github.com/jackc/pgx/v4 v4.11.0
-	github.com/mattn/go-sqlite3 v2.0.3+incompatible // indirect
+	github.com/mattn/go-sqlite3 v2.0.3+incompatible
+	github.com/sijms/go-ora/v2 v2.7.6
 )
@@ -0,0 +1,273 @@
+// Copyright (C) 2021 Pau Sanchez
+package lib
+
+import (
+	"context"
+	"log"
+	"strings"
+)
+
+// ClickHouse databases are documented as schemas. Besides comments, the table
+// engine, partition key and sorting key are read as tags of each table
+// (e.g. @engine:MergeTree @sorting_key:"user_id, date").
+
+const clickhouseSystemDatabases = `('system', 'INFORMATION_SCHEMA', 'information_schema')`
+
+// -----------------------------------------------------------------------------
+// getClickhouseDbLayout
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) getClickhouseDbLayout(ctx context.Context) (*DbLayout, error) {
+	dbLayout := NewDbLayout(conn.dbName)
+	dbLayout.Type = DbTypeClickhouse
+
+	conn.LogProgress("Reading columns")
+	err := conn.fetchClickhouseColumnInfo(ctx, &dbLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.LogProgress("Reading tables")
+	err = conn.fetchClickhouseTableInfo(ctx, &dbLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.LogProgress("Reading database comments")
+	err = conn.fetchClickhouseDatabaseComments(ctx, &dbLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	if conn.withStats {
+		conn.LogProgress("Reading table statistics")
+		err = conn.fetchClickhouseTableStats(ctx, &dbLayout)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &dbLayout, nil
+}
+
+// -----------------------------------------------------------------------------
+// clickhouseType
+//
+// Nullable(T) is documented as T and nullable, including low cardinality
+// types: LowCardinality(Nullable(String)) is LowCardinality(String)
+// -----------------------------------------------------------------------------
+func clickhouseType(columnType string) (string, bool) {
+	switch {
+	case strings.HasPrefix(columnType, "Nullable(") && strings.HasSuffix(columnType, ")"):
+		return columnType[len("Nullable(") : len(columnType)-1], true
+
+	case strings.HasPrefix(columnType, "LowCardinality(Nullable(") && strings.HasSuffix(columnType, "))"):
+		inner := columnType[len("LowCardinality(Nullable(") : len(columnType)-2]
+		return "LowCardinality(" + inner + ")", true
+	}
+
+	return columnType, false
+}
+
+// -----------------------------------------------------------------------------
+// fetchClickhouseColumnInfo
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) fetchClickhouseColumnInfo(ctx context.Context, dbLayout *DbLayout) error {
+	type ChColumnDef struct {
+		Database       string `db:"database"`
+		Table          string `db:"table"`
+		Name           string `db:"name"`
+		Type           string `db:"type"`
+		Default        string `db:"default_expression"`
+		Comment        string `db:"comment"`
+		IsInPrimaryKey uint8  `db:"is_in_primary_key"`
+	}
+
+	chColumns := []ChColumnDef{}
+
+	err := conn.SelectContext(
+		ctx,
+		&chColumns,
+		`SELECT database,
+		        table,
+		        name,
+		        type,
+		        default_expression,
+		        comment,
+		        is_in_primary_key
+		   FROM system.columns
+		  WHERE database NOT IN `+clickhouseSystemDatabases+`
+		  ORDER BY database, table, position
+		`,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, chColumn := range chColumns {
+		field := NewDbFieldLayout(chColumn.Name)
+		field.Type, field.IsNullable = clickhouseType(chColumn.Type)
+		field.IsPrimaryKey = chColumn.IsInPrimaryKey != 0
+		field.Default = chColumn.Default
+		field.Comment = chColumn.Comment
+
+		// types already have length in the type itself (e.g. FixedString(16))
+		field.Length = 0
+
+		err := dbLayout.AddField(
+			chColumn.Database,
+			chColumn.Table,
+			field,
+		)
+
+		if err != nil {
+			log.Println("Ignoring error:", err)
+		}
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// fetchClickhouseTableInfo
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) fetchClickhouseTableInfo(ctx context.Context, dbLayout *DbLayout) error {
+	type ChTableDef struct {
+		Database     string `db:"database"`
+		Name         string `db:"name"`
+		Engine       string `db:"engine"`
+		PartitionKey string `db:"partition_key"`
+		SortingKey   string `db:"sorting_key"`
+		Comment      string `db:"comment"`
+	}
+
+	chTables := []ChTableDef{}
+
+	err := conn.SelectContext(
+		ctx,
+		&chTables,
+		`SELECT database,
+		        name,
+		        engine,
+		        partition_key,
+		        sorting_key,
+		        comment
+		   FROM system.tables
+		  WHERE database NOT IN `+clickhouseSystemDatabases+`
+		    AND is_temporary = 0
+		`,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, chTable := range chTables {
+		table := dbLayout.GetTable(chTable.Database, chTable.Name)
+		if table == nil {
+			continue
+		}
+
+		table.Comment = chTable.Comment
+
+		metadata := []DbTag{
+			DbTag{Name: "engine", Value: chTable.Engine},
+			DbTag{Name: "partition_key", Value: chTable.PartitionKey},
+			DbTag{Name: "sorting_key", Value: chTable.SortingKey},
+		}
+		for _, tag := range metadata {
+			if tag.Value != "" {
+				table.Tags = table.Tags.Add(tag)
+			}
+		}
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// fetchClickhouseDatabaseComments
+//
+// Database comments are only available on recent versions (22.x), so they
+// are just skipped on older ones
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) fetchClickhouseDatabaseComments(ctx context.Context, dbLayout *DbLayout) error {
+	type ChDatabaseDef struct {
+		Name    string `db:"name"`
+		Comment string `db:"comment"`
+	}
+
+	chDatabases := []ChDatabaseDef{}
+
+	err := conn.SelectContext(
+		ctx,
+		&chDatabases,
+		`SELECT name, comment
+		   FROM system.databases
+		  WHERE name NOT IN `+clickhouseSystemDatabases+`
+		`,
+	)
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+
+		log.Println("Ignoring database comments:", err)
+		return nil
+	}
+
+	for _, chDatabase := range chDatabases {
+		// only databases with tables are documented
+		if schema, ok := dbLayout.SchemaLookup[chDatabase.Name]; ok {
+			schema.Comment = chDatabase.Comment
+		}
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// fetchClickhouseTableStats
+//
+// Rows and bytes are only known for some engines (e.g. MergeTree), and
+// ClickHouse keeps no statistics about when tables were analyzed
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) fetchClickhouseTableStats(ctx context.Context, dbLayout *DbLayout) error {
+	type ChTableStats struct {
+		Database string `db:"database"`
+		Name     string `db:"name"`
+		Rows     int64  `db:"total_rows"`
+		Size     int64  `db:"total_bytes"`
+	}
+
+	chTableStats := []ChTableStats{}
+
+	err := conn.SelectContext(
+		ctx,
+		&chTableStats,
+		`SELECT database,
+		        name,
+		        ifNull(toInt64(total_rows), -1) AS total_rows,
+		        ifNull(toInt64(total_bytes), -1) AS total_bytes
+		   FROM system.tables
+		  WHERE database NOT IN `+clickhouseSystemDatabases+`
+		    AND is_temporary = 0
+		`,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, chStats := range chTableStats {
+		stats := DbTableStats{
+			Rows:         chStats.Rows,
+			Size:         chStats.Size,
+			LastAnalyzed: "",
+		}
+
+		table := dbLayout.LookupTable(chStats.Database, chStats.Name)
+		if table != nil && !stats.IsEmpty() {
+			table.Stats = &stats
+		}
+	}
+
+	return nil
+}
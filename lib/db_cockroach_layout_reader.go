@@ -0,0 +1,188 @@
+// Copyright (C) 2021 Pau Sanchez
+package lib
+
+import (
+	"context"
+)
+
+// CockroachDB uses the postgres reader, except for the queries below, since
+// it has its own virtual schemas, hidden columns (e.g. rowid) and it lacks some
+// of the postgres catalog tables (pg_statio_all_tables, pg_shdescription...)
+
+const cockroachColumnsQuery = postgresColumnsSelect + `
+	 WHERE c.table_schema NOT IN ('information_schema', 'pg_catalog', 'crdb_internal', 'pg_extension')
+	   AND c.is_hidden = 'NO'
+`
+
+// -----------------------------------------------------------------------------
+// getCockroachDbComments
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) getCockroachDbComments(ctx context.Context, dbLayout *DbLayout) error {
+	var err error
+
+	err = conn.getCockroachDatabaseComment(ctx, dbLayout)
+	if err != nil {
+		return err
+	}
+
+	err = conn.getCockroachDbSchemaComments(ctx, dbLayout)
+	if err != nil {
+		return err
+	}
+
+	err = conn.getCockroachDbTableComments(ctx, dbLayout)
+	if err != nil {
+		return err
+	}
+
+	err = conn.getCockroachDbColumnComments(ctx, dbLayout)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// getCockroachDatabaseComment
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) getCockroachDatabaseComment(ctx context.Context, dbLayout *DbLayout) error {
+	var comments []string
+
+	err := conn.SelectContext(
+		ctx,
+		&comments,
+		`SELECT COALESCE(comment, '') as comment
+		   FROM [SHOW DATABASES WITH COMMENT]
+		  WHERE database_name = $1
+		`,
+		dbLayout.Name,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	if len(comments) >= 1 {
+		dbLayout.Comment = comments[0]
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// getCockroachDbSchemaComments
+//
+// Namespaces are not cast to regnamespace, oids are used instead
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) getCockroachDbSchemaComments(ctx context.Context, dbLayout *DbLayout) error {
+	type SchemaComment struct {
+		SchemaName string
+		Comment    string
+	}
+
+	crdbComments := []SchemaComment{}
+
+	err := conn.SelectContext(
+		ctx,
+		&crdbComments,
+		`SELECT nspname AS schema_name,
+		        COALESCE(obj_description(oid, 'pg_namespace'), '') AS comment
+		   FROM pg_catalog.pg_namespace
+		  WHERE nspname NOT IN ('pg_catalog', 'information_schema', 'crdb_internal', 'pg_extension')
+		`,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	for _, comment := range crdbComments {
+		schema := dbLayout.GetOrCreateSchema(comment.SchemaName)
+		if schema != nil {
+			schema.Comment = comment.Comment
+		}
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// getCockroachDbTableComments
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) getCockroachDbTableComments(ctx context.Context, dbLayout *DbLayout) error {
+	type TableComment struct {
+		TableSchema string
+		TableName   string
+		Comment     string
+	}
+
+	crdbComments := []TableComment{}
+
+	err := conn.SelectContext(
+		ctx,
+		&crdbComments,
+		`SELECT n.nspname AS table_schema,
+		        c.relname AS table_name,
+		        COALESCE(obj_description(c.oid, 'pg_class'), '') AS comment
+		   FROM pg_catalog.pg_class c
+		  INNER JOIN pg_catalog.pg_namespace n ON (n.oid = c.relnamespace)
+		  WHERE c.relkind = 'r'
+		`,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	for _, comment := range crdbComments {
+		table := dbLayout.GetTable(comment.TableSchema, comment.TableName)
+		if table != nil {
+			table.Comment = comment.Comment
+		}
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// getCockroachDbColumnComments
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) getCockroachDbColumnComments(ctx context.Context, dbLayout *DbLayout) error {
+	type ColumnComment struct {
+		TableSchema string
+		TableName   string
+		ColumnName  string
+		Comment     string
+	}
+
+	crdbComments := []ColumnComment{}
+
+	err := conn.SelectContext(
+		ctx,
+		&crdbComments,
+		`SELECT n.nspname AS table_schema,
+		        c.relname AS table_name,
+		        a.attname AS column_name,
+		        col_description(c.oid, a.attnum) AS comment
+		   FROM pg_catalog.pg_attribute a
+		  INNER JOIN pg_catalog.pg_class c ON (c.oid = a.attrelid)
+		  INNER JOIN pg_catalog.pg_namespace n ON (n.oid = c.relnamespace)
+		  WHERE a.attnum > 0
+		    AND col_description(c.oid, a.attnum) IS NOT NULL
+		`,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	for _, comment := range crdbComments {
+		field := dbLayout.GetField(comment.TableSchema, comment.TableName, comment.ColumnName)
+		if field != nil {
+			field.Comment = comment.Comment
+		}
+	}
+
+	return nil
+}
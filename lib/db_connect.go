@@ -2,33 +2,43 @@
 package lib
 
 import (
-	"os"
 	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"io"
 	"strings"
+	"time"
 
 	// SQL drivers
+	_ "github.com/ClickHouse/clickhouse-go"
 	_ "github.com/denisenkom/go-mssqldb"
 	"github.com/georgysavva/scany/sqlscan"
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/jackc/pgx/v4/stdlib"
 	_ "github.com/mattn/go-sqlite3"
+	_ "github.com/sijms/go-ora/v2"
 )
 
 const (
-	DriverPostgres = "pgx"
-	DriverMysql    = "mysql"
-	DriverMssql    = "sqlserver"
-	DriverSqlite   = "sqlite3"
+	DriverPostgres   = "pgx"
+	DriverMysql      = "mysql"
+	DriverMssql      = "sqlserver"
+	DriverSqlite     = "sqlite3"
+	DriverClickhouse = "clickhouse"
+	DriverDuckdb     = "duckdb"
+	DriverOracle     = "oracle"
 )
 
 type DbConnection struct {
 	db               *sql.DB
 	connectionString string
 	driverType       string
+	driver           *DbDriver
 	dbName           string
+	queryTimeout     time.Duration // no timeout when zero
+	progress         io.Writer     // no progress is reported when nil
+	withStats        bool          // read table statistics too
 }
 
 // -----------------------------------------------------------------------------
@@ -39,7 +49,11 @@ func NewDbConnection() *DbConnection {
 		db:               nil,
 		connectionString: "undefined",
 		driverType:       "unknown",
+		driver:           nil,
 		dbName:           "undefined",
+		queryTimeout:     0,
+		progress:         nil,
+		withStats:        false,
 	}
 
 	return &conn
@@ -59,6 +73,20 @@ func (conn *DbConnection) GetDriverType() string {
 	return conn.driverType
 }
 
+// -----------------------------------------------------------------------------
+// GetDriver
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) GetDriver() *DbDriver {
+	return conn.driver
+}
+
+// -----------------------------------------------------------------------------
+// GetDbName
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) GetDbName() string {
+	return conn.dbName
+}
+
 // -----------------------------------------------------------------------------
 // GetConnectionString
 // -----------------------------------------------------------------------------
@@ -66,12 +94,103 @@ func (conn *DbConnection) GetConnectionString() string {
 	return conn.connectionString
 }
 
+// -----------------------------------------------------------------------------
+// SetQueryTimeout
+//
+// Maximum time each query can take, on top of any deadline of the context
+// given to the readers. Zero means no timeout.
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) SetQueryTimeout(timeout time.Duration) {
+	conn.queryTimeout = timeout
+}
+
+// -----------------------------------------------------------------------------
+// SetProgress
+//
+// Report progress of the readers (schemas and tables read) to given writer.
+// Use nil to disable it.
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) SetProgress(progress io.Writer) {
+	conn.progress = progress
+}
+
+// -----------------------------------------------------------------------------
+// SetWithStats
+//
+// Read table statistics (estimated rows, size on disk and last time they were
+// analyzed) along with the layout, on the databases that have them
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) SetWithStats(withStats bool) {
+	conn.withStats = withStats
+}
+
+// -----------------------------------------------------------------------------
+// LogProgress
+//
+// Report progress when enabled with SetProgress
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) LogProgress(format string, args ...interface{}) {
+	if conn.progress != nil {
+		fmt.Fprintf(conn.progress, format+"\n", args...)
+	}
+}
+
+// -----------------------------------------------------------------------------
+// QueryContext
+//
+// Returns a context for a single query, with the query timeout if any
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) QueryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if conn.queryTimeout > 0 {
+		return context.WithTimeout(ctx, conn.queryTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// -----------------------------------------------------------------------------
+// SelectContext
+//
+// Run given query scanning all rows into dst (see sqlscan), honoring the
+// query timeout
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) SelectContext(
+	ctx context.Context,
+	dst interface{},
+	query string,
+	args ...interface{},
+) error {
+	ctx, cancel := conn.QueryContext(ctx)
+	defer cancel()
+
+	return sqlscan.Select(ctx, conn.db, dst, query, args...)
+}
+
+// -----------------------------------------------------------------------------
+// SelectTxContext
+//
+// Same as SelectContext, but inside given transaction
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) SelectTxContext(
+	ctx context.Context,
+	tx *sql.Tx,
+	dst interface{},
+	query string,
+	args ...interface{},
+) error {
+	ctx, cancel := conn.QueryContext(ctx)
+	defer cancel()
+
+	return sqlscan.Select(ctx, tx, dst, query, args...)
+}
+
 // -----------------------------------------------------------------------------
 // DbConnect
 //
-// Helper method to connect to database.
+// Helper method to connect to database. The connection is checked, so it
+// fails as soon as given context is done when the database cannot be reached.
 // -----------------------------------------------------------------------------
 func DbConnect(
+	ctx context.Context,
 	dbtype string,
 	dbhost string,
 	dbport uint,
@@ -84,70 +203,47 @@ func DbConnect(
 ) {
 	dbtype = strings.ToLower(dbtype)
 	if dbtype == "" || dbtype == "auto" {
-		return tryDbConnect(dbhost, dbport, dbuser, dbpass, dbname)
+		return tryDbConnect(ctx, dbhost, dbport, dbuser, dbpass, dbname)
 	}
 
-	isZeroPort := (dbport == 0)
-	noUser := (dbuser == "")
-
-	conn := NewDbConnection()
-	conn.dbName = dbname
-
-	if noUser {
-		dbuser = "root"
+	driver := GetDbDriver(dbtype)
+	if driver == nil {
+		return nil, errors.New(
+			"Unsupported database type. Try with: " + strings.Join(GetDbDriverNames(), " | "),
+		)
 	}
 
-	switch dbtype {
-	case "pg", "postgres", "pgx":
-		if isZeroPort {
-			dbport = 5432
-		}
-
-		conn.driverType = DriverPostgres
-		conn.connectionString = fmt.Sprintf(
-			"postgres://%s:%s@%s:%d/%s", dbuser, dbpass, dbhost, dbport, dbname,
-		)
-	case "mysql", "mariadb":
-		if isZeroPort {
-			dbport = 3306
-		}
-		conn.driverType = DriverMysql
-		conn.connectionString = fmt.Sprintf(
-			"%s:%s@tcp(%s:%d)/%s", dbuser, dbpass, dbhost, dbport, dbname,
-		)
+	if dbport == 0 {
+		dbport = driver.DefaultPort
+	}
 
-	case "mssql", "sqlserver":
-		if isZeroPort {
-			dbport = 1433
-		}
-		if noUser {
-			dbuser = "sa"
-		}
-		conn.driverType = DriverMssql
-		conn.connectionString = fmt.Sprintf(
-			"sqlserver://%s:%s@%s:%d/?database=%s", dbuser, dbpass, dbhost, dbport, dbname,
-		)
+	if dbuser == "" {
+		dbuser = driver.DefaultUser
+	}
 
-	case "sqlite", "sqlite3":
-		conn.driverType = DriverSqlite
-		conn.connectionString = fmt.Sprintf("file:%s?cache=shared&mode=ro", dbhost)
+	connectionString, err := driver.Dsn(dbhost, dbport, dbuser, dbpass, dbname)
+	if err != nil {
+		return nil, err
+	}
 
-	  if _, err := os.Stat(dbhost); err != nil {
-	  	return nil, errors.New(fmt.Sprintf("File %s should exist", dbhost))
-	  }
+	conn := NewDbConnection()
+	conn.dbName = dbname
+	conn.driver = driver
+	conn.driverType = driver.SqlDriver
+	conn.connectionString = connectionString
 
-	default:
-		conn = nil
-		return nil, errors.New("Unsupported database type. Try with: pg | mysql | mssql | sqlite")
+	conn.db, err = sql.Open(conn.driverType, conn.connectionString)
+	if err != nil {
+		return nil, err
 	}
 
-	var err error
-	conn.db, err = sql.Open(conn.driverType, conn.connectionString)
+	err = conn.db.PingContext(ctx)
 	if err != nil {
+		conn.Close()
 		return nil, err
 	}
 
-	return conn, err
+	return conn, nil
 }
 
 // -----------------------------------------------------------------------------
@@ -158,6 +254,7 @@ func DbConnect(
 //
 // -----------------------------------------------------------------------------
 func tryDbConnect(
+	ctx context.Context,
 	dbhost string,
 	dbport uint,
 	dbuser string,
@@ -167,15 +264,12 @@ func tryDbConnect(
 	*DbConnection,
 	error,
 ) {
-	drivers := []string{DriverSqlite, DriverPostgres, DriverMssql, DriverMysql}
-
-	for _, driver := range drivers {
-		conn, err := DbConnect(driver, dbhost, dbport, dbuser, dbpass, dbname)
+	for _, driver := range dbDrivers {
+		conn, err := DbConnect(ctx, driver.Name, dbhost, dbport, dbuser, dbpass, dbname)
 		if err == nil {
 			// port is open BUT it might be another db... let's try a simple query
 			result := []int{}
-			ctx := context.Background()
-			err = sqlscan.Select(ctx, conn.db, &result, `SELECT 1`)
+			err = conn.SelectContext(ctx, &result, driver.ProbeQuery)
 			if err != nil {
 				conn.Close()
 				continue
@@ -184,6 +278,11 @@ func tryDbConnect(
 			// success!
 			return conn, nil
 		}
+
+		// do not try any other driver when cancelled or timed out
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 	}
 
 	return nil, errors.New("Could not connect to the database. Try specifying the database type.")
@@ -201,22 +300,150 @@ func (conn *DbConnection) Close() {
 
 // -----------------------------------------------------------------------------
 // GetLayout
+//
+// Read the layout from the database. Reading stops as soon as given context
+// is done (e.g. cancelled or timed out).
 // -----------------------------------------------------------------------------
-func (conn *DbConnection) GetLayout() (*DbLayout, error) {
+func (conn *DbConnection) GetLayout(ctx context.Context) (*DbLayout, error) {
 	if conn.db == nil {
 		return nil, errors.New("Not connected to any database")
 	}
 
-	switch conn.driverType {
-	case DriverPostgres:
-		return conn.getPostgresDbLayout()
-	case DriverMysql:
-		return conn.getMysqlDbLayout()
-	case DriverMssql:
-		return conn.getMssqlDbLayout()
-	case DriverSqlite:
-		return conn.getSqliteDbLayout()
-	default:
+	var dbLayout *DbLayout
+	var err error
+
+	conn.LogProgress("Reading layout of %s database '%s'", conn.driverType, conn.dbName)
+
+	if conn.driver == nil {
 		return nil, errors.New("Don't know how to read db layout for " + conn.driverType + " databases")
 	}
+
+	dbLayout, err = conn.driver.Reader.ReadLayout(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, schemaLayout := range dbLayout.Schemas {
+		conn.LogProgress("Read schema '%s': %d table(s)", schemaLayout.Name, len(schemaLayout.Tables))
+	}
+
+	dbLayout.CatalogTags = conn.driver.CatalogTags
+
+	// tags might have been written on database comments too
+	dbLayout.ExtractTags()
+	return dbLayout, nil
+}
+
+// -----------------------------------------------------------------------------
+// DbSyncItem
+//
+// Table or field (when Field is set) whose comment or tags should be written
+// to the database
+// -----------------------------------------------------------------------------
+type DbSyncItem struct {
+	Schema  string
+	Table   string
+	Field   string
+	Comment string
+	Tags    DbTags
+}
+
+// -----------------------------------------------------------------------------
+// String
+// -----------------------------------------------------------------------------
+func (item DbSyncItem) String() string {
+	return DbTaggedItem{Schema: item.Schema, Table: item.Table, Field: item.Field}.String()
+}
+
+// -----------------------------------------------------------------------------
+// getSyncItems
+//
+// Returns tables and fields of given layout whose comment or tags differ from
+// the ones in database. Items that do not exist in database (including the
+// ones marked as deleted) are ignored, and so are catalog tags, since they are
+// not read from comments.
+// -----------------------------------------------------------------------------
+func getSyncItems(layout *DbLayout, dbLayout *DbLayout, catalogTags []string) []DbSyncItem {
+	items := []DbSyncItem{}
+
+	for _, schemaLayout := range layout.Schemas {
+		for _, tableLayout := range schemaLayout.Tables {
+			if strings.HasPrefix(tableLayout.Name, DeletedPrefix) {
+				continue
+			}
+
+			dbTable := dbLayout.LookupTable(schemaLayout.Name, tableLayout.Name)
+			if dbTable == nil {
+				continue
+			}
+
+			tags := tableLayout.Tags.Remove(catalogTags...)
+			if dbTable.Comment != tableLayout.Comment || dbTable.Tags.Remove(catalogTags...).String() != tags.String() {
+				items = append(items, DbSyncItem{
+					Schema:  schemaLayout.Name,
+					Table:   tableLayout.Name,
+					Comment: tableLayout.Comment,
+					Tags:    tags,
+				})
+			}
+
+			for _, field := range tableLayout.Fields {
+				if strings.HasPrefix(field.Name, DeletedPrefix) {
+					continue
+				}
+
+				dbField := dbLayout.LookupField(schemaLayout.Name, tableLayout.Name, field.Name)
+				if dbField == nil {
+					continue
+				}
+
+				tags := field.Tags.Remove(catalogTags...)
+				if dbField.Comment != field.Comment || dbField.Tags.Remove(catalogTags...).String() != tags.String() {
+					items = append(items, DbSyncItem{
+						Schema:  schemaLayout.Name,
+						Table:   tableLayout.Name,
+						Field:   field.Name,
+						Comment: field.Comment,
+						Tags:    tags,
+					})
+				}
+			}
+		}
+	}
+
+	return items
+}
+
+// -----------------------------------------------------------------------------
+// SyncLayout
+//
+// Write comments and tags of tables and fields of given layout to the
+// database, so they live in both places. Only items that exist in database and
+// have changed are written. Returns the items that have been updated.
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) SyncLayout(ctx context.Context, layout *DbLayout) ([]DbSyncItem, error) {
+	if conn.db == nil {
+		return nil, errors.New("Not connected to any database")
+	}
+
+	if conn.driver == nil || conn.driver.Writer == nil {
+		return nil, errors.New("Don't know how to write db layout for " + conn.driverType + " databases")
+	}
+
+	dbLayout, err := conn.GetLayout(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	items := getSyncItems(layout, dbLayout, conn.driver.CatalogTags)
+	if len(items) == 0 {
+		return items, nil
+	}
+
+	err = conn.driver.Writer.WriteLayout(ctx, conn, items)
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
 }
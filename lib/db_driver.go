@@ -0,0 +1,378 @@
+// Copyright (C) 2021 Pau Sanchez
+package lib
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Databases are supported through drivers registered with RegisterDbDriver,
+// which is all that is needed to add a new database from another package:
+//
+//   func init() {
+//     lib.RegisterDbDriver(&lib.DbDriver{
+//       Name:        "mydb",
+//       SqlDriver:   "mydb",
+//       DefaultPort: 1234,
+//       Dsn:         func(...) (string, error) { ... },
+//       ProbeQuery:  "SELECT 1",
+//       Reader:      lib.LayoutReaderFunc(readMyDbLayout),
+//     })
+//   }
+
+// -----------------------------------------------------------------------------
+// LayoutReader
+//
+// Reads the layout of the database of given connection
+// -----------------------------------------------------------------------------
+type LayoutReader interface {
+	ReadLayout(ctx context.Context, conn *DbConnection) (*DbLayout, error)
+}
+
+// -----------------------------------------------------------------------------
+// LayoutReaderFunc
+//
+// Adapter to use a function as a LayoutReader
+// -----------------------------------------------------------------------------
+type LayoutReaderFunc func(ctx context.Context, conn *DbConnection) (*DbLayout, error)
+
+// -----------------------------------------------------------------------------
+// ReadLayout
+// -----------------------------------------------------------------------------
+func (f LayoutReaderFunc) ReadLayout(ctx context.Context, conn *DbConnection) (*DbLayout, error) {
+	return f(ctx, conn)
+}
+
+// -----------------------------------------------------------------------------
+// LayoutWriter
+//
+// Writes comments and tags of given items to the database of the connection
+// -----------------------------------------------------------------------------
+type LayoutWriter interface {
+	WriteLayout(ctx context.Context, conn *DbConnection, items []DbSyncItem) error
+}
+
+// -----------------------------------------------------------------------------
+// LayoutWriterFunc
+//
+// Adapter to use a function as a LayoutWriter
+// -----------------------------------------------------------------------------
+type LayoutWriterFunc func(ctx context.Context, conn *DbConnection, items []DbSyncItem) error
+
+// -----------------------------------------------------------------------------
+// WriteLayout
+// -----------------------------------------------------------------------------
+func (f LayoutWriterFunc) WriteLayout(ctx context.Context, conn *DbConnection, items []DbSyncItem) error {
+	return f(ctx, conn, items)
+}
+
+// -----------------------------------------------------------------------------
+// DbDriver
+// -----------------------------------------------------------------------------
+type DbDriver struct {
+	Name        string   // name used with -t
+	Aliases     []string // other names accepted with -t
+	SqlDriver   string   // database/sql driver name
+	DefaultPort uint     // used when no port is given, zero if not needed
+	DefaultUser string   // used when no user is given
+
+	// connection string for database/sql
+	Dsn func(host string, port uint, user string, pass string, dbname string) (string, error)
+
+	ProbeQuery string       // query to tell whether we are connected to this kind of database
+	Reader     LayoutReader // reads the layout
+	Writer     LayoutWriter // optional, writes comments back (-sync-to-db)
+
+	// tags read by the reader from the catalog instead of the comments (e.g.
+	// identity), which are never written back and always taken from the
+	// database on merges
+	CatalogTags []string
+
+	// optional, query returning the first rows of given columns of a table
+	// (-profile)
+	SampleQuery func(schema string, table string, columns []string, rows int) string
+}
+
+var dbDrivers = []*DbDriver{}
+
+// -----------------------------------------------------------------------------
+// RegisterDbDriver
+//
+// Make given driver available to DbConnect. Drivers are tried in the order
+// they were registered when the database type is not known.
+// -----------------------------------------------------------------------------
+func RegisterDbDriver(driver *DbDriver) error {
+	if driver.Name == "" || driver.SqlDriver == "" || driver.Dsn == nil || driver.Reader == nil {
+		return errors.New("Database drivers need at least a name, sql driver, dsn and reader")
+	}
+
+	for _, name := range append([]string{driver.Name}, driver.Aliases...) {
+		if GetDbDriver(name) != nil {
+			return fmt.Errorf("There is already a database driver named '%s'", name)
+		}
+	}
+
+	if driver.ProbeQuery == "" {
+		driver.ProbeQuery = "SELECT 1"
+	}
+
+	dbDrivers = append(dbDrivers, driver)
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// GetDbDriver
+//
+// Returns the driver with given name or alias (case insensitive), or nil
+// -----------------------------------------------------------------------------
+func GetDbDriver(name string) *DbDriver {
+	for _, driver := range dbDrivers {
+		if strings.EqualFold(driver.Name, name) {
+			return driver
+		}
+		for _, alias := range driver.Aliases {
+			if strings.EqualFold(alias, name) {
+				return driver
+			}
+		}
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// GetDbDriverNames
+//
+// Returns the names of all registered drivers, in order
+// -----------------------------------------------------------------------------
+func GetDbDriverNames() []string {
+	names := make([]string, 0, len(dbDrivers))
+	for _, driver := range dbDrivers {
+		names = append(names, driver.Name)
+	}
+	return names
+}
+
+// -----------------------------------------------------------------------------
+// isSqlDriverAvailable
+//
+// Some database/sql drivers are only included with build tags (e.g. duckdb)
+// -----------------------------------------------------------------------------
+func isSqlDriverAvailable(name string) bool {
+	for _, driver := range sql.Drivers() {
+		if driver == name {
+			return true
+		}
+	}
+	return false
+}
+
+// -----------------------------------------------------------------------------
+// postgresDsn
+// -----------------------------------------------------------------------------
+func postgresDsn(host string, port uint, user string, pass string, dbname string) (string, error) {
+	return fmt.Sprintf("postgres://%s:%s@%s:%d/%s", user, pass, host, port, dbname), nil
+}
+
+var postgresReader = LayoutReaderFunc(func(ctx context.Context, conn *DbConnection) (*DbLayout, error) {
+	return conn.getPostgresDbLayout(ctx)
+})
+
+var postgresWriter = LayoutWriterFunc(func(ctx context.Context, conn *DbConnection, items []DbSyncItem) error {
+	return conn.syncPostgresDbLayout(ctx, items)
+})
+
+var postgresCatalogTags = []string{"identity", "stored", "serial", "collation"}
+
+var postgresSampleQuery = limitSampleQuery(postgresQuoteIdentifier)
+
+// -----------------------------------------------------------------------------
+// init
+//
+// Built-in drivers, in the order they are tried when the type is not known
+// -----------------------------------------------------------------------------
+func init() {
+	builtinDrivers := []*DbDriver{
+		&DbDriver{
+			Name:        "sqlite",
+			Aliases:     []string{DriverSqlite},
+			SqlDriver:   DriverSqlite,
+			DefaultPort: 0,
+			DefaultUser: "",
+			Dsn: func(host string, port uint, user string, pass string, dbname string) (string, error) {
+				if _, err := os.Stat(host); err != nil {
+					return "", fmt.Errorf("File %s should exist", host)
+				}
+				return fmt.Sprintf("file:%s?cache=shared&mode=ro", host), nil
+			},
+			// files are opened lazily, so read the header to tell it is sqlite
+			ProbeQuery: "SELECT count(*) FROM sqlite_master",
+			Reader: LayoutReaderFunc(func(ctx context.Context, conn *DbConnection) (*DbLayout, error) {
+				return conn.getSqliteDbLayout(ctx)
+			}),
+			Writer:      nil,
+			CatalogTags: nil,
+			SampleQuery: limitSampleQuery(postgresQuoteIdentifier),
+		},
+		&DbDriver{
+			Name:        "duckdb",
+			Aliases:     []string{},
+			SqlDriver:   DriverDuckdb,
+			DefaultPort: 0,
+			DefaultUser: "",
+			Dsn: func(host string, port uint, user string, pass string, dbname string) (string, error) {
+				if !isSqlDriverAvailable(DriverDuckdb) {
+					return "", errors.New("DuckDB support is not included in this build, build with: go build -tags duckdb")
+				}
+				if _, err := os.Stat(host); err != nil {
+					return "", fmt.Errorf("File %s should exist", host)
+				}
+				return fmt.Sprintf("%s?access_mode=read_only", host), nil
+			},
+			ProbeQuery: "SELECT count(*) FROM duckdb_tables()",
+			Reader: LayoutReaderFunc(func(ctx context.Context, conn *DbConnection) (*DbLayout, error) {
+				return conn.getDuckdbDbLayout(ctx)
+			}),
+			Writer:      nil,
+			CatalogTags: nil,
+			SampleQuery: limitSampleQuery(postgresQuoteIdentifier),
+		},
+		&DbDriver{
+			Name:        "pg",
+			Aliases:     []string{"postgres", DriverPostgres},
+			SqlDriver:   DriverPostgres,
+			DefaultPort: 5432,
+			DefaultUser: "root",
+			Dsn:         postgresDsn,
+			ProbeQuery:  "SELECT 1",
+			Reader:      postgresReader,
+			Writer:      postgresWriter,
+			CatalogTags: postgresCatalogTags,
+			SampleQuery: postgresSampleQuery,
+		},
+		&DbDriver{
+			Name:        "mssql",
+			Aliases:     []string{DriverMssql},
+			SqlDriver:   DriverMssql,
+			DefaultPort: 1433,
+			DefaultUser: "sa",
+			Dsn: func(host string, port uint, user string, pass string, dbname string) (string, error) {
+				return fmt.Sprintf("sqlserver://%s:%s@%s:%d/?database=%s", user, pass, host, port, dbname), nil
+			},
+			ProbeQuery: "SELECT 1",
+			Reader: LayoutReaderFunc(func(ctx context.Context, conn *DbConnection) (*DbLayout, error) {
+				return conn.getMssqlDbLayout(ctx)
+			}),
+			Writer: LayoutWriterFunc(func(ctx context.Context, conn *DbConnection, items []DbSyncItem) error {
+				return conn.syncMssqlDbLayout(ctx, items)
+			}),
+			CatalogTags: []string{"identity", "stored", "virtual", "view"},
+			SampleQuery: mssqlSampleQuery,
+		},
+		&DbDriver{
+			Name:        "mysql",
+			Aliases:     []string{"mariadb"},
+			SqlDriver:   DriverMysql,
+			DefaultPort: 3306,
+			DefaultUser: "root",
+			Dsn: func(host string, port uint, user string, pass string, dbname string) (string, error) {
+				return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", user, pass, host, port, dbname), nil
+			},
+			ProbeQuery: "SELECT 1",
+			Reader: LayoutReaderFunc(func(ctx context.Context, conn *DbConnection) (*DbLayout, error) {
+				return conn.getMysqlDbLayout(ctx)
+			}),
+			Writer: nil,
+			CatalogTags: []string{
+				"auto_increment", "on_update", "virtual", "stored",
+				"charset", "collation", "engine", "row_format",
+			},
+			SampleQuery: limitSampleQuery(mysqlQuoteIdentifier),
+		},
+		&DbDriver{
+			Name:        "oracle",
+			Aliases:     []string{"ora"},
+			SqlDriver:   DriverOracle,
+			DefaultPort: 1521,
+			DefaultUser: "system",
+			Dsn: func(host string, port uint, user string, pass string, dbname string) (string, error) {
+				// database name is the service name (e.g. FREEPDB1)
+				dsn := url.URL{
+					Scheme: "oracle",
+					User:   url.UserPassword(user, pass),
+					Host:   fmt.Sprintf("%s:%d", host, port),
+					Path:   "/" + dbname,
+				}
+				return dsn.String(), nil
+			},
+			ProbeQuery: "SELECT 1 FROM DUAL",
+			Reader: LayoutReaderFunc(func(ctx context.Context, conn *DbConnection) (*DbLayout, error) {
+				return conn.getOracleDbLayout(ctx)
+			}),
+			Writer: LayoutWriterFunc(func(ctx context.Context, conn *DbConnection, items []DbSyncItem) error {
+				return conn.syncOracleDbLayout(ctx, items)
+			}),
+			CatalogTags: nil,
+			SampleQuery: oracleSampleQuery,
+		},
+
+		// postgres compatible engines, told apart by the postgres reader itself
+		&DbDriver{
+			Name:        "cockroach",
+			Aliases:     []string{"cockroachdb", "crdb"},
+			SqlDriver:   DriverPostgres,
+			DefaultPort: 26257,
+			DefaultUser: "root",
+			Dsn:         postgresDsn,
+			ProbeQuery:  "SELECT 1",
+			Reader:      postgresReader,
+			Writer:      postgresWriter,
+			CatalogTags: postgresCatalogTags,
+			SampleQuery: postgresSampleQuery,
+		},
+		&DbDriver{
+			Name:        "yugabyte",
+			Aliases:     []string{"yugabytedb", "yb"},
+			SqlDriver:   DriverPostgres,
+			DefaultPort: 5433,
+			DefaultUser: "yugabyte",
+			Dsn:         postgresDsn,
+			ProbeQuery:  "SELECT 1",
+			Reader:      postgresReader,
+			Writer:      postgresWriter,
+			CatalogTags: postgresCatalogTags,
+			SampleQuery: postgresSampleQuery,
+		},
+		&DbDriver{
+			Name:        "clickhouse",
+			Aliases:     []string{"ch"},
+			SqlDriver:   DriverClickhouse,
+			DefaultPort: 9000,
+			DefaultUser: "default",
+			Dsn: func(host string, port uint, user string, pass string, dbname string) (string, error) {
+				query := url.Values{}
+				query.Set("username", user)
+				query.Set("password", pass)
+				query.Set("database", dbname)
+				return fmt.Sprintf("tcp://%s:%d?%s", host, port, query.Encode()), nil
+			},
+			ProbeQuery: "SELECT 1",
+			Reader: LayoutReaderFunc(func(ctx context.Context, conn *DbConnection) (*DbLayout, error) {
+				return conn.getClickhouseDbLayout(ctx)
+			}),
+			Writer:      nil,
+			CatalogTags: []string{"engine", "partition_key", "sorting_key"},
+			SampleQuery: limitSampleQuery(mysqlQuoteIdentifier),
+		},
+	}
+
+	for _, driver := range builtinDrivers {
+		if err := RegisterDbDriver(driver); err != nil {
+			panic(err)
+		}
+	}
+}
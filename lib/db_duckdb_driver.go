@@ -0,0 +1,13 @@
+// Copyright (C) 2021 Pau Sanchez
+
+//go:build duckdb
+// +build duckdb
+
+package lib
+
+// DuckDB driver is only included when building with -tags duckdb, since it
+// needs cgo and links the whole DuckDB library into the binary
+
+import (
+	_ "github.com/marcboeker/go-duckdb"
+)
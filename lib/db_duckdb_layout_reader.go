@@ -0,0 +1,321 @@
+// Copyright (C) 2021 Pau Sanchez
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+)
+
+// DuckDB files are read using the duckdb_*() catalog functions, which also
+// expose comments (COMMENT ON). Only tables and views of the database that is
+// open are read, ignoring temporary and internal ones.
+
+// -----------------------------------------------------------------------------
+// getDuckdbDbLayout
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) getDuckdbDbLayout(ctx context.Context) (*DbLayout, error) {
+	dbLayout := NewDbLayout(conn.dbName)
+	dbLayout.Type = DbTypeDuckdb
+
+	conn.LogProgress("Reading columns")
+	err := conn.fetchDuckdbColumnInfo(ctx, &dbLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.LogProgress("Reading table comments")
+	err = conn.fetchDuckdbTableComments(ctx, &dbLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.LogProgress("Reading schema comments")
+	err = conn.fetchDuckdbSchemaComments(ctx, &dbLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.LogProgress("Reading constraints")
+	err = conn.fetchDuckdbConstraints(ctx, &dbLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	if conn.withStats {
+		conn.LogProgress("Reading table statistics")
+		err = conn.fetchDuckdbTableStats(ctx, &dbLayout)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &dbLayout, nil
+}
+
+// -----------------------------------------------------------------------------
+// fetchDuckdbColumnInfo
+//
+// duckdb_columns() returns the columns of both tables and views
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) fetchDuckdbColumnInfo(ctx context.Context, dbLayout *DbLayout) error {
+	type DuckdbColumnDef struct {
+		SchemaName string `db:"schema_name"`
+		TableName  string `db:"table_name"`
+		ColumnName string `db:"column_name"`
+		DataType   string `db:"data_type"`
+		IsNullable bool   `db:"is_nullable"`
+		Default    string `db:"column_default"`
+		Comment    string `db:"comment"`
+	}
+
+	duckdbColumns := []DuckdbColumnDef{}
+
+	err := conn.SelectContext(
+		ctx,
+		&duckdbColumns,
+		`SELECT schema_name,
+		        table_name,
+		        column_name,
+		        data_type,
+		        is_nullable,
+		        COALESCE(column_default, '') AS column_default,
+		        COALESCE(comment, '') AS comment
+		   FROM duckdb_columns()
+		  WHERE database_name = current_database()
+		    AND NOT internal
+		  ORDER BY schema_name, table_name, column_index
+		`,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, duckdbColumn := range duckdbColumns {
+		field := NewDbFieldLayout(duckdbColumn.ColumnName)
+		field.Type = duckdbColumn.DataType
+		field.IsNullable = duckdbColumn.IsNullable
+		field.Default = duckdbColumn.Default
+		field.Comment = duckdbColumn.Comment
+
+		// types already have length in the type itself (e.g. DECIMAL(10,2))
+		field.Length = 0
+
+		err := dbLayout.AddField(
+			duckdbColumn.SchemaName,
+			duckdbColumn.TableName,
+			field,
+		)
+
+		if err != nil {
+			log.Println("Ignoring error:", err)
+		}
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// fetchDuckdbTableComments
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) fetchDuckdbTableComments(ctx context.Context, dbLayout *DbLayout) error {
+	type DuckdbTableComment struct {
+		SchemaName string `db:"schema_name"`
+		TableName  string `db:"table_name"`
+		Comment    string `db:"comment"`
+	}
+
+	duckdbComments := []DuckdbTableComment{}
+
+	err := conn.SelectContext(
+		ctx,
+		&duckdbComments,
+		`SELECT schema_name, table_name, comment
+		   FROM duckdb_tables()
+		  WHERE database_name = current_database()
+		    AND NOT internal AND NOT temporary
+		    AND comment IS NOT NULL
+		  UNION ALL
+		 SELECT schema_name, view_name AS table_name, comment
+		   FROM duckdb_views()
+		  WHERE database_name = current_database()
+		    AND NOT internal AND NOT temporary
+		    AND comment IS NOT NULL
+		`,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, comment := range duckdbComments {
+		table := dbLayout.GetTable(comment.SchemaName, comment.TableName)
+		if table != nil {
+			table.Comment = comment.Comment
+		}
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// fetchDuckdbSchemaComments
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) fetchDuckdbSchemaComments(ctx context.Context, dbLayout *DbLayout) error {
+	type DuckdbSchemaComment struct {
+		SchemaName string `db:"schema_name"`
+		Comment    string `db:"comment"`
+	}
+
+	duckdbComments := []DuckdbSchemaComment{}
+
+	err := conn.SelectContext(
+		ctx,
+		&duckdbComments,
+		`SELECT schema_name, comment
+		   FROM duckdb_schemas()
+		  WHERE database_name = current_database()
+		    AND NOT internal
+		    AND comment IS NOT NULL
+		`,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, comment := range duckdbComments {
+		// only schemas with tables are documented
+		if schema, ok := dbLayout.SchemaLookup[comment.SchemaName]; ok {
+			schema.Comment = comment.Comment
+		}
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// fetchDuckdbConstraints
+//
+// Primary keys, single column unique constraints and foreign keys. Column
+// lists are read as JSON since the driver returns lists as []interface{}
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) fetchDuckdbConstraints(ctx context.Context, dbLayout *DbLayout) error {
+	type DuckdbConstraintDef struct {
+		SchemaName        string `db:"schema_name"`
+		TableName         string `db:"table_name"`
+		ConstraintType    string `db:"constraint_type"`
+		ColumnNames       string `db:"column_names"`
+		ReferencedTable   string `db:"referenced_table"`
+		ReferencedColumns string `db:"referenced_columns"`
+	}
+
+	duckdbConstraints := []DuckdbConstraintDef{}
+
+	err := conn.SelectContext(
+		ctx,
+		&duckdbConstraints,
+		`SELECT schema_name,
+		        table_name,
+		        constraint_type,
+		        to_json(constraint_column_names)::VARCHAR AS column_names,
+		        COALESCE(referenced_table, '') AS referenced_table,
+		        COALESCE(to_json(referenced_column_names)::VARCHAR, '[]') AS referenced_columns
+		   FROM duckdb_constraints()
+		  WHERE database_name = current_database()
+		    AND constraint_type IN ('PRIMARY KEY', 'UNIQUE', 'FOREIGN KEY')
+		  ORDER BY schema_name, table_name, constraint_index
+		`,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, constraint := range duckdbConstraints {
+		columnNames := []string{}
+		if err := json.Unmarshal([]byte(constraint.ColumnNames), &columnNames); err != nil {
+			return err
+		}
+
+		switch constraint.ConstraintType {
+		case "PRIMARY KEY":
+			for _, columnName := range columnNames {
+				field := dbLayout.GetField(constraint.SchemaName, constraint.TableName, columnName)
+				if field != nil {
+					field.IsPrimaryKey = true
+				}
+			}
+
+		case "UNIQUE":
+			if len(columnNames) != 1 {
+				continue
+			}
+
+			field := dbLayout.GetField(constraint.SchemaName, constraint.TableName, columnNames[0])
+			if field != nil {
+				field.IsUnique = true
+			}
+
+		case "FOREIGN KEY":
+			referencedColumns := []string{}
+			if err := json.Unmarshal([]byte(constraint.ReferencedColumns), &referencedColumns); err != nil {
+				return err
+			}
+
+			// the schema of the referenced table is not available, and it is
+			// the same one on most databases
+			dbLayout.Refs = append(dbLayout.Refs, &DbRefLayout{
+				Name:       "",
+				FromSchema: constraint.SchemaName,
+				FromTable:  constraint.TableName,
+				FromFields: columnNames,
+				Relation:   ">",
+				ToSchema:   constraint.SchemaName,
+				ToTable:    constraint.ReferencedTable,
+				ToFields:   referencedColumns,
+				Settings:   "",
+			})
+		}
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// fetchDuckdbTableStats
+//
+// Only the estimated number of rows of each table is available
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) fetchDuckdbTableStats(ctx context.Context, dbLayout *DbLayout) error {
+	type DuckdbTableStats struct {
+		SchemaName string `db:"schema_name"`
+		TableName  string `db:"table_name"`
+		Rows       int64  `db:"estimated_size"`
+	}
+
+	duckdbStats := []DuckdbTableStats{}
+
+	err := conn.SelectContext(
+		ctx,
+		&duckdbStats,
+		`SELECT schema_name, table_name, estimated_size
+		   FROM duckdb_tables()
+		  WHERE database_name = current_database()
+		    AND NOT internal AND NOT temporary
+		`,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, tableStats := range duckdbStats {
+		table := dbLayout.LookupTable(tableStats.SchemaName, tableStats.TableName)
+		if table != nil {
+			table.Stats = &DbTableStats{
+				Rows:         tableStats.Rows,
+				Size:         -1,
+				LastAnalyzed: "",
+			}
+		}
+	}
+
+	return nil
+}
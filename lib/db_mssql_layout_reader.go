@@ -3,68 +3,207 @@ package lib
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"fmt"
 	"log"
-
-	"github.com/georgysavva/scany/sqlscan"
+	"strings"
 )
 
-// NOTE: on the db writer, we need to use add or update depending on whether
-//       the attribute exists
-//        - sp_updateextendedproperty
-//        - sp_addextendedproperty
+// Columns are read from the catalog views (sys.*) rather than from
+// INFORMATION_SCHEMA, which lacks identity and computed columns. Besides
+// comments, the following is read as tags:
+//   - @view on views
+//   - @identity:"1,1" with the seed and increment of identity fields
+//   - @stored:([price]*[quantity]) or @virtual:... on computed fields,
+//     depending on whether they are persisted
 
 // -----------------------------------------------------------------------------
 // getMssqlDbLayout
 // -----------------------------------------------------------------------------
-func (conn *DbConnection) getMssqlDbLayout() (*DbLayout, error) {
+func (conn *DbConnection) getMssqlDbLayout(ctx context.Context) (*DbLayout, error) {
 	dbLayout := NewDbLayout(conn.dbName)
 	dbLayout.Type = DbTypeMssql
 
-	err := conn.fetchMssqlColumnInfo(&dbLayout)
+	conn.LogProgress("Reading columns")
+	err := conn.fetchMssqlColumnInfo(ctx, &dbLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.LogProgress("Reading comments and extended properties")
+	err = conn.fetchMssqlLayoutComments(ctx, &dbLayout)
 	if err != nil {
 		return nil, err
 	}
 
-	err = conn.fetchMssqlLayoutComments(&dbLayout)
+	conn.LogProgress("Reading sequences")
+	err = conn.fetchMssqlSequences(ctx, &dbLayout)
 	if err != nil {
 		return nil, err
 	}
 
+	conn.LogProgress("Reading triggers")
+	err = conn.fetchMssqlTriggers(ctx, &dbLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.LogProgress("Reading security policies")
+	err = conn.fetchMssqlSecurityPolicies(ctx, &dbLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	if conn.withStats {
+		conn.LogProgress("Reading table statistics")
+		err = conn.fetchMssqlTableStats(ctx, &dbLayout)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &dbLayout, nil
 }
 
+// -----------------------------------------------------------------------------
+// mssqlType
+//
+// Returns the type as it would be written on a CREATE TABLE, since length,
+// precision and scale are on their own columns. Lengths are in bytes, so they
+// are halved for unicode types, and -1 stands for max.
+// -----------------------------------------------------------------------------
+func mssqlType(typeName string, maxLength int64, precision int64, scale int64) string {
+	switch typeName {
+	case "varchar", "char", "varbinary", "binary":
+		if maxLength == -1 {
+			return typeName + "(max)"
+		}
+		return fmt.Sprintf("%s(%d)", typeName, maxLength)
+
+	case "nvarchar", "nchar":
+		if maxLength == -1 {
+			return typeName + "(max)"
+		}
+		return fmt.Sprintf("%s(%d)", typeName, maxLength/2)
+
+	case "decimal", "numeric":
+		return fmt.Sprintf("%s(%d,%d)", typeName, precision, scale)
+
+	case "datetime2", "datetimeoffset", "time":
+		// 7 is the default fractional seconds precision
+		if scale != 7 {
+			return fmt.Sprintf("%s(%d)", typeName, scale)
+		}
+
+	case "float":
+		if precision != 53 {
+			return fmt.Sprintf("float(%d)", precision)
+		}
+	}
+
+	// e.g. int, datetime, uniqueidentifier or user defined types
+	return typeName
+}
+
+// -----------------------------------------------------------------------------
+// mssqlDefault
+//
+// SQL Server wraps defaults in parenthesis, sometimes twice (e.g. ((1)) or
+// (getdate())), which are removed as long as they enclose the whole default
+// -----------------------------------------------------------------------------
+func mssqlDefault(definition string) string {
+	for strings.HasPrefix(definition, "(") && strings.HasSuffix(definition, ")") {
+		depth := 0
+		enclosed := true
+		for i, c := range definition {
+			switch c {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+			if depth == 0 && i < len(definition)-1 {
+				enclosed = false
+				break
+			}
+		}
+
+		if !enclosed {
+			break
+		}
+		definition = definition[1 : len(definition)-1]
+	}
+
+	return definition
+}
+
 // -----------------------------------------------------------------------------
 // fetchMssqlColumnInfo
+//
+// Columns of user tables and views, skipping the ones created by SQL Server
+// itself or its tools (e.g. sysdiagrams)
 // -----------------------------------------------------------------------------
-func (conn *DbConnection) fetchMssqlColumnInfo(dbLayout *DbLayout) error {
-	type MyColumnDef struct {
-		TableSchema   string `db:"TABLE_SCHEMA"`
-		TableName     string `db:"TABLE_NAME"`
-		ColumnName    string `db:"COLUMN_NAME"`
-		IsNullable    string `db:"IS_NULLABLE"` // YES | NO
-		ColumnType    string `db:"COLUMN_TYPE"`
-		MaxLength     uint32 `db:"MAX_LENGTH"`
-		ColumnDefault string `db:"COLUMN_DEFAULT"` // Default value
+func (conn *DbConnection) fetchMssqlColumnInfo(ctx context.Context, dbLayout *DbLayout) error {
+	type MsColumnDef struct {
+		TableSchema       string `db:"TABLE_SCHEMA"`
+		TableName         string `db:"TABLE_NAME"`
+		ObjectType        string `db:"OBJECT_TYPE"` // U | V
+		ColumnName        string `db:"COLUMN_NAME"`
+		IsNullable        bool   `db:"IS_NULLABLE"`
+		TypeName          string `db:"TYPE_NAME"`
+		MaxLength         int64  `db:"MAX_LENGTH"`
+		Precision         int64  `db:"PRECISION"`
+		Scale             int64  `db:"SCALE"`
+		IsIdentity        bool   `db:"IS_IDENTITY"`
+		IdentitySeed      string `db:"IDENTITY_SEED"`
+		IdentityIncrement string `db:"IDENTITY_INCREMENT"`
+		IsComputed        bool   `db:"IS_COMPUTED"`
+		IsPersisted       bool   `db:"IS_PERSISTED"`
+		ComputedColumn    string `db:"COMPUTED_DEFINITION"`
+		ColumnDefault     string `db:"DEFAULT_DEFINITION"`
 	}
 
-	dbFields := []MyColumnDef{}
+	dbFields := []MsColumnDef{}
 
-	// schema in MYSQL refers to database, whereas we keep postgres definition
-	ctx := context.Background()
-	err := sqlscan.Select(
+	err := conn.SelectContext(
 		ctx,
-		conn.db,
 		&dbFields,
-		`SELECT TABLE_SCHEMA,
-						TABLE_NAME,
-		        COLUMN_NAME,
-		        COALESCE(COLUMN_DEFAULT, '') as COLUMN_DEFAULT,
-		        IS_NULLABLE,
-		        COALESCE(CHARACTER_MAXIMUM_LENGTH, 0) as MAX_LENGTH,
-		        DATA_TYPE AS COLUMN_TYPE
-		   FROM INFORMATION_SCHEMA.COLUMNS
-  	`,
+		`SELECT s.name AS TABLE_SCHEMA,
+		        o.name AS TABLE_NAME,
+		        RTRIM(o.type) AS OBJECT_TYPE,
+		        c.name AS COLUMN_NAME,
+		        c.is_nullable AS IS_NULLABLE,
+		        t.name AS TYPE_NAME,
+		        CONVERT(INT, c.max_length) AS MAX_LENGTH,
+		        CONVERT(INT, c.precision) AS PRECISION,
+		        CONVERT(INT, c.scale) AS SCALE,
+		        c.is_identity AS IS_IDENTITY,
+		        COALESCE(CONVERT(NVARCHAR(100), ic.seed_value), '') AS IDENTITY_SEED,
+		        COALESCE(CONVERT(NVARCHAR(100), ic.increment_value), '') AS IDENTITY_INCREMENT,
+		        c.is_computed AS IS_COMPUTED,
+		        COALESCE(cc.is_persisted, CONVERT(BIT, 0)) AS IS_PERSISTED,
+		        COALESCE(cc.definition, '') AS COMPUTED_DEFINITION,
+		        COALESCE(dc.definition, '') AS DEFAULT_DEFINITION
+		   FROM sys.columns c
+		  INNER JOIN sys.objects o ON (o.object_id = c.object_id)
+		  INNER JOIN sys.schemas s ON (s.schema_id = o.schema_id)
+		  INNER JOIN sys.types t ON (t.user_type_id = c.user_type_id)
+		   LEFT JOIN sys.identity_columns ic ON (ic.object_id = c.object_id AND ic.column_id = c.column_id)
+		   LEFT JOIN sys.computed_columns cc ON (cc.object_id = c.object_id AND cc.column_id = c.column_id)
+		   LEFT JOIN sys.default_constraints dc ON (dc.object_id = c.default_object_id)
+		  WHERE o.type IN ('U', 'V')
+		    AND o.is_ms_shipped = 0
+		    AND NOT EXISTS (
+		      SELECT 1
+		        FROM sys.extended_properties ep
+		       WHERE ep.class = 1
+		         AND ep.major_id = o.object_id
+		         AND ep.minor_id = 0
+		         AND ep.name = 'microsoft_database_tools_support'
+		    )
+		  ORDER BY s.name, o.name, c.column_id
+		`,
 	)
 	if err != nil {
 		return err
@@ -72,15 +211,28 @@ func (conn *DbConnection) fetchMssqlColumnInfo(dbLayout *DbLayout) error {
 
 	for _, dbField := range dbFields {
 		field := NewDbFieldLayout(dbField.ColumnName)
-		field.Type = dbField.ColumnType
-		field.IsNullable = dbField.IsNullable == "YES"
+		field.Type = mssqlType(dbField.TypeName, dbField.MaxLength, dbField.Precision, dbField.Scale)
+		field.IsNullable = dbField.IsNullable
+		field.Default = mssqlDefault(dbField.ColumnDefault)
 
 		// types already have length in the type itself
 		field.Length = 0
 
+		if dbField.IsIdentity {
+			field.Tags = field.Tags.Add(DbTag{
+				Name:  "identity",
+				Value: dbField.IdentitySeed + "," + dbField.IdentityIncrement,
+			})
+		}
+
+		if dbField.IsComputed && dbField.IsPersisted {
+			field.Tags = field.Tags.Add(DbTag{Name: "stored", Value: dbField.ComputedColumn})
+		} else if dbField.IsComputed {
+			field.Tags = field.Tags.Add(DbTag{Name: "virtual", Value: dbField.ComputedColumn})
+		}
+
 		// TODO: field.IsPrimaryKey
 		// TODO: field.IsUnique
-		// TODO: field.Default
 
 		err := dbLayout.AddField(
 			dbField.TableSchema,
@@ -90,6 +242,12 @@ func (conn *DbConnection) fetchMssqlColumnInfo(dbLayout *DbLayout) error {
 
 		if err != nil {
 			log.Println("Ignoring error:", err)
+			continue
+		}
+
+		if dbField.ObjectType == "V" {
+			table := dbLayout.GetTable(dbField.TableSchema, dbField.TableName)
+			table.Tags = table.Tags.Add(DbTag{Name: "view", Value: ""})
 		}
 	}
 
@@ -98,41 +256,85 @@ func (conn *DbConnection) fetchMssqlColumnInfo(dbLayout *DbLayout) error {
 
 // -----------------------------------------------------------------------------
 // fetchMssqlLayoutComments
+//
+// Read all extended properties at once. Properties of schemas (class 3) have
+// the id of the schema as major_id, whereas the ones of tables, views and
+// columns (class 1) have the id of the object, so both are joined separately.
 // -----------------------------------------------------------------------------
-func (conn *DbConnection) fetchMssqlLayoutComments(dbLayout *DbLayout) error {
-	var err error
-	dbLayout.Comment, err = conn.fetchMssqlComment(nil, nil, nil)
+func (conn *DbConnection) fetchMssqlLayoutComments(ctx context.Context, dbLayout *DbLayout) error {
+	type MsPropertyDef struct {
+		Class      int64  `db:"CLASS"` // 0 database | 1 object or column | 3 schema
+		SchemaName string `db:"SCHEMA_NAME"`
+		TableName  string `db:"TABLE_NAME"`
+		ColumnName string `db:"COLUMN_NAME"`
+		Name       string `db:"NAME"`
+		Value      string `db:"VALUE"`
+	}
+
+	properties := []MsPropertyDef{}
+
+	err := conn.SelectContext(
+		ctx,
+		&properties,
+		`SELECT CONVERT(INT, ep.class) AS CLASS,
+		        COALESCE(s.name, '') AS SCHEMA_NAME,
+		        COALESCE(o.name, '') AS TABLE_NAME,
+		        COALESCE(c.name, '') AS COLUMN_NAME,
+		        CONVERT(NVARCHAR(4000), ep.name) AS NAME,
+		        COALESCE(CONVERT(NVARCHAR(MAX), ep.value), '') AS VALUE
+		   FROM sys.extended_properties ep
+		   LEFT JOIN sys.objects o
+		     ON (ep.class = 1 AND o.object_id = ep.major_id)
+		   LEFT JOIN sys.columns c
+		     ON (ep.class = 1 AND ep.minor_id > 0 AND c.object_id = ep.major_id AND c.column_id = ep.minor_id)
+		   LEFT JOIN sys.schemas s
+		     ON (s.schema_id = CASE WHEN ep.class = 3 THEN ep.major_id ELSE o.schema_id END)
+		  WHERE ep.class IN (0, 1, 3)
+		`,
+	)
 	if err != nil {
 		return err
 	}
 
-	// According to stackoverflow we could use the following query to order to
-	// extract all comments at once, but after some tests something is odd for
-	// schema comments... so I prefer a slower, but correct approach
-	//
-	//   SELECT S.name as schema_name, O.name AS table_name, c.name as col_name, ep.value AS value
-	//   FROM sys.extended_properties EP
-	//   LEFT JOIN sys.all_objects O ON ep.major_id = O.object_id
-	//   LEFT JOIN sys.schemas S on O.schema_id = S.schema_id
-	//   LEFT JOIN sys.columns AS c ON ep.major_id = c.object_id AND ep.minor_id = c.column_id
-	//   WHERE ep.name = 'MS_Description'
-
-	for _, schemaLayout := range dbLayout.Schemas {
-		schemaLayout.Comment, err = conn.fetchMssqlComment(&schemaLayout.Name, nil, nil)
-		if err != nil {
-			return err
+	for _, property := range properties {
+		isComment := property.Name == MssqlCommentProperty
+		if !isComment && strings.HasPrefix(property.Name, "MS_") {
+			continue
 		}
+		tag := DbTag{Name: property.Name, Value: property.Value}
+
+		switch {
+		case property.Class == 0:
+			if isComment {
+				dbLayout.Comment = property.Value
+			}
 
-		for _, tableLayout := range schemaLayout.Tables {
-			tableLayout.Comment, err = conn.fetchMssqlComment(&schemaLayout.Name, &tableLayout.Name, nil)
-			if err != nil {
-				return err
+		case property.Class == 3:
+			schemaLayout, ok := dbLayout.SchemaLookup[property.SchemaName]
+			if ok && isComment {
+				schemaLayout.Comment = property.Value
 			}
-			for _, field := range tableLayout.Fields {
-				field.Comment, err = conn.fetchMssqlComment(&schemaLayout.Name, &tableLayout.Name, &field.Name)
-				if err != nil {
-					return err
-				}
+
+		case property.ColumnName != "":
+			field := dbLayout.GetField(property.SchemaName, property.TableName, property.ColumnName)
+			if field == nil {
+				continue
+			}
+			if isComment {
+				field.Comment = property.Value
+			} else {
+				field.Tags = field.Tags.Add(tag)
+			}
+
+		default:
+			table := dbLayout.GetTable(property.SchemaName, property.TableName)
+			if table == nil {
+				continue
+			}
+			if isComment {
+				table.Comment = property.Value
+			} else {
+				table.Tags = table.Tags.Add(tag)
 			}
 		}
 	}
@@ -141,78 +343,366 @@ func (conn *DbConnection) fetchMssqlLayoutComments(dbLayout *DbLayout) error {
 }
 
 // -----------------------------------------------------------------------------
-// fetchMssqlLayoutComments
+// fetchMssqlSequences
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) fetchMssqlSequences(ctx context.Context, dbLayout *DbLayout) error {
+	type MsSequenceDef struct {
+		SchemaName   string `db:"SCHEMA_NAME"`
+		SequenceName string `db:"SEQUENCE_NAME"`
+		TypeName     string `db:"TYPE_NAME"`
+		Increment    int64  `db:"INCREMENT"`
+		Comment      string `db:"COMMENT"`
+	}
+
+	sequenceDefList := []MsSequenceDef{}
+
+	err := conn.SelectContext(
+		ctx,
+		&sequenceDefList,
+		`SELECT s.name AS SCHEMA_NAME,
+		        sq.name AS SEQUENCE_NAME,
+		        TYPE_NAME(sq.user_type_id) AS TYPE_NAME,
+		        CONVERT(BIGINT, sq.increment) AS INCREMENT,
+		        COALESCE(CONVERT(NVARCHAR(MAX), ep.value), '') AS COMMENT
+		   FROM sys.sequences sq
+		  INNER JOIN sys.schemas s ON (s.schema_id = sq.schema_id)
+		   LEFT JOIN sys.extended_properties ep
+		     ON (ep.class = 1 AND ep.major_id = sq.object_id AND ep.minor_id = 0 AND ep.name = 'MS_Description')
+		  WHERE sq.is_ms_shipped = 0
+		`,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, sequenceDef := range sequenceDefList {
+		sequence := NewDbSequenceLayout(sequenceDef.SequenceName)
+		sequence.Type = sequenceDef.TypeName
+		sequence.Increment = sequenceDef.Increment
+		sequence.Comment = sequenceDef.Comment
+
+		schema := dbLayout.GetOrCreateSchema(sequenceDef.SchemaName)
+		schema.Sequences = append(schema.Sequences, &sequence)
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// fetchMssqlTriggers
+//
+// DML triggers of tables and views, one row for each event. SQL Server
+// triggers always run once per statement, and run a statement of their own
+// rather than a function.
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) fetchMssqlTriggers(ctx context.Context, dbLayout *DbLayout) error {
+	type MsTriggerDef struct {
+		SchemaName  string `db:"SCHEMA_NAME"`
+		TableName   string `db:"TABLE_NAME"`
+		TriggerName string `db:"TRIGGER_NAME"`
+		IsInsteadOf bool   `db:"IS_INSTEAD_OF"`
+		Event       string `db:"EVENT"`
+		Comment     string `db:"COMMENT"`
+	}
+
+	triggerDefList := []MsTriggerDef{}
+
+	err := conn.SelectContext(
+		ctx,
+		&triggerDefList,
+		`SELECT s.name AS SCHEMA_NAME,
+		        o.name AS TABLE_NAME,
+		        tr.name AS TRIGGER_NAME,
+		        tr.is_instead_of_trigger AS IS_INSTEAD_OF,
+		        te.type_desc AS EVENT,
+		        COALESCE(CONVERT(NVARCHAR(MAX), ep.value), '') AS COMMENT
+		   FROM sys.triggers tr
+		  INNER JOIN sys.trigger_events te ON (te.object_id = tr.object_id)
+		  INNER JOIN sys.objects o ON (o.object_id = tr.parent_id)
+		  INNER JOIN sys.schemas s ON (s.schema_id = o.schema_id)
+		   LEFT JOIN sys.extended_properties ep
+		     ON (ep.class = 1 AND ep.major_id = tr.object_id AND ep.minor_id = 0 AND ep.name = 'MS_Description')
+		  WHERE tr.parent_class = 1
+		    AND tr.is_ms_shipped = 0
+		  ORDER BY tr.object_id, te.type
+		`,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, triggerDef := range triggerDefList {
+		table := dbLayout.GetTable(triggerDef.SchemaName, triggerDef.TableName)
+		if table == nil {
+			continue
+		}
+
+		// events of the same trigger come together
+		last := len(table.Triggers) - 1
+		if last >= 0 && table.Triggers[last].Name == triggerDef.TriggerName {
+			table.Triggers[last].Events = append(table.Triggers[last].Events, triggerDef.Event)
+			continue
+		}
+
+		trigger := NewDbTriggerLayout(triggerDef.TriggerName)
+		trigger.Timing = "AFTER"
+		if triggerDef.IsInsteadOf {
+			trigger.Timing = "INSTEAD OF"
+		}
+		trigger.Events = []string{triggerDef.Event}
+		trigger.Level = "STATEMENT"
+		trigger.Comment = triggerDef.Comment
+
+		table.Triggers = append(table.Triggers, &trigger)
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// fetchMssqlSecurityPolicies
 //
-// Helper function to get a comment for an object:
-//   - database comment with everything null
+// Security policies are documented on each of the tables they apply to, with
+// their filter predicates as USING and their block predicates as WITH CHECK.
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) fetchMssqlSecurityPolicies(ctx context.Context, dbLayout *DbLayout) error {
+	type MsPredicateDef struct {
+		SchemaName    string `db:"SCHEMA_NAME"`
+		TableName     string `db:"TABLE_NAME"`
+		PolicyName    string `db:"POLICY_NAME"`
+		PredicateType string `db:"PREDICATE_TYPE"` // FILTER | BLOCK
+		Definition    string `db:"DEFINITION"`
+		Comment       string `db:"COMMENT"`
+	}
+
+	predicateDefList := []MsPredicateDef{}
+
+	err := conn.SelectContext(
+		ctx,
+		&predicateDefList,
+		`SELECT s.name AS SCHEMA_NAME,
+		        o.name AS TABLE_NAME,
+		        sp.name AS POLICY_NAME,
+		        pr.predicate_type_desc AS PREDICATE_TYPE,
+		        pr.predicate_definition AS DEFINITION,
+		        COALESCE(CONVERT(NVARCHAR(MAX), ep.value), '') AS COMMENT
+		   FROM sys.security_policies sp
+		  INNER JOIN sys.security_predicates pr ON (pr.object_id = sp.object_id)
+		  INNER JOIN sys.objects o ON (o.object_id = pr.target_object_id)
+		  INNER JOIN sys.schemas s ON (s.schema_id = o.schema_id)
+		   LEFT JOIN sys.extended_properties ep
+		     ON (ep.class = 1 AND ep.major_id = sp.object_id AND ep.minor_id = 0 AND ep.name = 'MS_Description')
+		  ORDER BY sp.object_id, o.object_id, pr.security_predicate_id
+		`,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, predicateDef := range predicateDefList {
+		table := dbLayout.GetTable(predicateDef.SchemaName, predicateDef.TableName)
+		if table == nil {
+			continue
+		}
+
+		// predicates of the same policy on the same table come together
+		last := len(table.Policies) - 1
+		if last < 0 || table.Policies[last].Name != predicateDef.PolicyName {
+			policy := NewDbPolicyLayout(predicateDef.PolicyName)
+			policy.Command = "ALL"
+			policy.Comment = predicateDef.Comment
+			table.Policies = append(table.Policies, &policy)
+			last++
+		}
+
+		policy := table.Policies[last]
+		if predicateDef.PredicateType == "FILTER" {
+			policy.Using = singleLine(predicateDef.Definition)
+		} else {
+			policy.Check = singleLine(predicateDef.Definition)
+		}
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// fetchMssqlTableStats
+//
+// Rows are counted on the heap or clustered index of each partition, and the
+// size includes all indexes. Tables are analyzed when their most recent
+// statistics were updated. VIEW DATABASE STATE permission is required.
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) fetchMssqlTableStats(ctx context.Context, dbLayout *DbLayout) error {
+	type MsTableStats struct {
+		TableSchema  string `db:"TABLE_SCHEMA"`
+		TableName    string `db:"TABLE_NAME"`
+		Rows         int64  `db:"ROW_COUNT"`
+		Size         int64  `db:"TABLE_SIZE"`
+		LastAnalyzed string `db:"LAST_ANALYZED"`
+	}
+
+	tableStatsList := []MsTableStats{}
+
+	err := conn.SelectContext(
+		ctx,
+		&tableStatsList,
+		`SELECT s.name AS TABLE_SCHEMA,
+		        o.name AS TABLE_NAME,
+		        CONVERT(BIGINT, SUM(CASE WHEN ps.index_id IN (0, 1) THEN ps.row_count ELSE 0 END)) AS ROW_COUNT,
+		        CONVERT(BIGINT, SUM(ps.reserved_page_count)) * 8192 AS TABLE_SIZE,
+		        COALESCE(CONVERT(VARCHAR(19), (
+		          SELECT MAX(STATS_DATE(st.object_id, st.stats_id))
+		            FROM sys.stats st
+		           WHERE st.object_id = o.object_id
+		        ), 120), '') AS LAST_ANALYZED
+		   FROM sys.dm_db_partition_stats ps
+		  INNER JOIN sys.objects o ON (o.object_id = ps.object_id)
+		  INNER JOIN sys.schemas s ON (s.schema_id = o.schema_id)
+		  WHERE o.type = 'U'
+		    AND o.is_ms_shipped = 0
+		  GROUP BY s.name, o.name, o.object_id
+		`,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, tableStats := range tableStatsList {
+		table := dbLayout.LookupTable(tableStats.TableSchema, tableStats.TableName)
+		if table != nil {
+			table.Stats = &DbTableStats{
+				Rows:         tableStats.Rows,
+				Size:         tableStats.Size,
+				LastAnalyzed: tableStats.LastAnalyzed,
+			}
+		}
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// MssqlExtendedProperty
+// -----------------------------------------------------------------------------
+type MssqlExtendedProperty struct {
+	Name  string
+	Value string
+}
+
+// MS_Description holds the comment, any other extended property not created by
+// SQL Server itself (MS_*) is taken as a tag
+const MssqlCommentProperty = "MS_Description"
+
+// -----------------------------------------------------------------------------
+// fetchMssqlExtendedProperties
+//
+// Helper function to get all extended properties of an object:
+//   - database properties with everything null
 //   - schema with only schema as string
 //   - table with schema + table set
 //   - column with schema + table + column set
+//
+// Views are taken into account as well. Properties are read inside given
+// transaction, so changes made by it are seen too.
 // -----------------------------------------------------------------------------
-func (conn *DbConnection) fetchMssqlComment(schema *string, table *string, column *string) (string, error) {
-	var result []string
+func (conn *DbConnection) fetchMssqlExtendedProperties(
+	ctx context.Context,
+	tx *sql.Tx,
+	schema *string,
+	table *string,
+	column *string,
+) (
+	[]MssqlExtendedProperty,
+	error,
+) {
+	var result []MssqlExtendedProperty
 	var err error
 
-	ctx := context.Background()
-
 	switch {
-	// query database comment
+	// query database properties
 	case schema == nil && table == nil && column == nil:
-		err = sqlscan.Select(
+		err = conn.SelectTxContext(
 			ctx,
-			conn.db,
+			tx,
 			&result,
-			`SELECT COALESCE(value, '')
-			   FROM::fn_listextendedproperty('MS_Description', NULL, NULL, NULL, NULL, NULL, NULL)
+			`SELECT CONVERT(NVARCHAR(4000), name) AS name,
+			        COALESCE(CONVERT(NVARCHAR(MAX), value), '') AS value
+			   FROM::fn_listextendedproperty(NULL, NULL, NULL, NULL, NULL, NULL, NULL)
 				`,
 		)
 
-	// query schema comment
+	// query schema properties
 	case schema != nil && table == nil && column == nil:
-		err = sqlscan.Select(
+		err = conn.SelectTxContext(
 			ctx,
-			conn.db,
+			tx,
 			&result,
-			`SELECT COALESCE(value, '')
-			   FROM::fn_listextendedproperty('MS_Description', 'schema', @p1, NULL, NULL, NULL, NULL)
+			`SELECT CONVERT(NVARCHAR(4000), name) AS name,
+			        COALESCE(CONVERT(NVARCHAR(MAX), value), '') AS value
+			   FROM::fn_listextendedproperty(NULL, 'schema', @p1, NULL, NULL, NULL, NULL)
 				`,
 			*schema,
 		)
 
-	// query table comment
+	// query table properties
 	case schema != nil && table != nil && column == nil:
-		err = sqlscan.Select(
+		err = conn.SelectTxContext(
 			ctx,
-			conn.db,
+			tx,
 			&result,
-			`SELECT COALESCE(value, '')
-			   FROM::fn_listextendedproperty('MS_Description', 'schema', @p1, 'table', @p2, NULL, NULL)
+			`SELECT CONVERT(NVARCHAR(4000), name) AS name,
+			        COALESCE(CONVERT(NVARCHAR(MAX), value), '') AS value
+			   FROM::fn_listextendedproperty(NULL, 'schema', @p1, @p3, @p2, NULL, NULL)
 				`,
 			*schema,
 			*table,
+			conn.mssqlObjectType(ctx, tx, *schema, *table),
 		)
 
-	// query column comment
+	// query column properties
 	case schema != nil && table != nil && column != nil:
-		err = sqlscan.Select(
+		err = conn.SelectTxContext(
 			ctx,
-			conn.db,
+			tx,
 			&result,
-			`SELECT COALESCE(value, '')
-			   FROM::fn_listextendedproperty('MS_Description', 'schema', @p1, 'table', @p2, 'column', @p3)
+			`SELECT CONVERT(NVARCHAR(4000), name) AS name,
+			        COALESCE(CONVERT(NVARCHAR(MAX), value), '') AS value
+			   FROM::fn_listextendedproperty(NULL, 'schema', @p1, @p4, @p2, 'column', @p3)
 				`,
 			*schema,
 			*table,
 			*column,
+			conn.mssqlObjectType(ctx, tx, *schema, *table),
 		)
 
 	default:
-		return "", errors.New("Invalid combination of parameters to retrieve comment")
+		return nil, errors.New("Invalid combination of parameters to retrieve comment")
 	}
 
-	if len(result) > 0 {
-		return result[0], nil
+	return result, err
+}
+
+// -----------------------------------------------------------------------------
+// mssqlObjectType
+//
+// Returns 'view' or 'table', as expected by extended property functions
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) mssqlObjectType(ctx context.Context, tx *sql.Tx, schema string, table string) string {
+	var result []int
+
+	err := conn.SelectTxContext(
+		ctx,
+		tx,
+		&result,
+		`SELECT COALESCE(OBJECTPROPERTY(OBJECT_ID(QUOTENAME(@p1) + '.' + QUOTENAME(@p2)), 'IsView'), 0)`,
+		schema,
+		table,
+	)
+
+	if err == nil && len(result) > 0 && result[0] == 1 {
+		return "view"
 	}
 
-	return "", err
+	return "table"
 }
@@ -0,0 +1,130 @@
+// Copyright (C) 2021 Pau Sanchez
+package lib
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+// setMssqlExtendedProperty
+//
+// Add, update or drop (when value is nil) an extended property of a table,
+// view or column, depending on whether it already exists
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) setMssqlExtendedProperty(
+	ctx context.Context,
+	tx *sql.Tx,
+	item DbSyncItem,
+	objectType string,
+	name string,
+	value *string,
+	exists bool,
+) error {
+	var procedure string
+	switch {
+	case value == nil && !exists:
+		return nil
+	case value == nil:
+		procedure = "sp_dropextendedproperty"
+	case exists:
+		procedure = "sp_updateextendedproperty"
+	default:
+		procedure = "sp_addextendedproperty"
+	}
+
+	var columnType, columnName interface{}
+	if item.Field != "" {
+		columnType = "COLUMN"
+		columnName = item.Field
+	}
+
+	args := []interface{}{
+		sql.Named("name", name),
+		sql.Named("level0type", "SCHEMA"),
+		sql.Named("level0name", item.Schema),
+		sql.Named("level1type", strings.ToUpper(objectType)),
+		sql.Named("level1name", item.Table),
+		sql.Named("level2type", columnType),
+		sql.Named("level2name", columnName),
+	}
+	if value != nil {
+		args = append(args, sql.Named("value", *value))
+	}
+
+	ctx, cancel := conn.QueryContext(ctx)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, procedure, args...)
+	if err != nil {
+		return fmt.Errorf("cannot update %s on %s: %s", name, item, err)
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// syncMssqlDbLayout
+//
+// Write comments as MS_Description and tags as custom extended properties.
+// Custom extended properties that are no longer tags get dropped.
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) syncMssqlDbLayout(ctx context.Context, items []DbSyncItem) error {
+	tx, err := conn.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, item := range items {
+		var column *string
+		if item.Field != "" {
+			column = &item.Field
+		}
+
+		properties, err := conn.fetchMssqlExtendedProperties(ctx, tx, &item.Schema, &item.Table, column)
+		if err != nil {
+			return err
+		}
+
+		existing := map[string]bool{}
+		for _, property := range properties {
+			existing[strings.ToLower(property.Name)] = true
+		}
+
+		objectType := conn.mssqlObjectType(ctx, tx, item.Schema, item.Table)
+
+		var comment *string
+		if item.Comment != "" {
+			comment = &item.Comment
+		}
+		err = conn.setMssqlExtendedProperty(
+			ctx, tx, item, objectType, MssqlCommentProperty, comment, existing[strings.ToLower(MssqlCommentProperty)],
+		)
+		if err != nil {
+			return err
+		}
+
+		for _, tag := range item.Tags {
+			value := tag.Value
+			err = conn.setMssqlExtendedProperty(ctx, tx, item, objectType, tag.Name, &value, existing[strings.ToLower(tag.Name)])
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, property := range properties {
+			if strings.HasPrefix(property.Name, "MS_") || item.Tags.Has(property.Name) {
+				continue
+			}
+			err = conn.setMssqlExtendedProperty(ctx, tx, item, objectType, property.Name, nil, true)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
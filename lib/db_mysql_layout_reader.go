@@ -4,61 +4,199 @@ package lib
 import (
 	"context"
 	"log"
-
-	"github.com/georgysavva/scany/sqlscan"
+	"strings"
 )
 
+// Besides comments, table engine, row format and collation are read as tags
+// of each table, and auto_increment, ON UPDATE, generated columns (with their
+// expression) and charset/collation as tags of each field. Column charset and
+// collation are only tagged when they differ from the ones of the table.
+
+// -----------------------------------------------------------------------------
+// getMysqlEngine
+//
+// MariaDB speaks the MySQL protocol, but it is told apart by its version
+// (e.g. 10.11.6-MariaDB-1:10.11.6+maria~ubu2204)
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) getMysqlEngine(ctx context.Context) (string, error) {
+	var versions []string
+
+	err := conn.SelectContext(ctx, &versions, `SELECT VERSION()`)
+	if err != nil {
+		return "", err
+	}
+
+	if len(versions) > 0 && strings.Contains(versions[0], "MariaDB") {
+		return DbTypeMariadb, nil
+	}
+
+	return DbTypeMysql, nil
+}
+
 // -----------------------------------------------------------------------------
 // getMysqlDbLayout
 // -----------------------------------------------------------------------------
-func (conn *DbConnection) getMysqlDbLayout() (*DbLayout, error) {
+func (conn *DbConnection) getMysqlDbLayout(ctx context.Context) (*DbLayout, error) {
+	engine, err := conn.getMysqlEngine(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	dbLayout := NewDbLayout(conn.dbName)
-	dbLayout.Type = DbTypeMysql
+	dbLayout.Type = engine
 
-	err := conn.fetchMysqlColumnInfo(&dbLayout)
+	conn.LogProgress("Reading columns")
+	err = conn.fetchMysqlColumnInfo(ctx, &dbLayout)
 	if err != nil {
 		return nil, err
 	}
 
-	err = conn.fetchMysqlTableInfo(&dbLayout)
+	conn.LogProgress("Reading tables")
+	err = conn.fetchMysqlTableInfo(ctx, &dbLayout)
 	if err != nil {
 		return nil, err
 	}
 
+	conn.LogProgress("Reading triggers")
+	err = conn.fetchMysqlTriggers(ctx, &dbLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	if conn.withStats {
+		conn.LogProgress("Reading table statistics")
+		err = conn.fetchMysqlTableStats(ctx, &dbLayout)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &dbLayout, nil
 }
 
+// -----------------------------------------------------------------------------
+// mysqlDefault
+//
+// Returns the default as it would be written on a CREATE TABLE. MySQL returns
+// literals unquoted and expressions without parenthesis, whereas MariaDB
+// returns them as they were written (and NULL for no default).
+// -----------------------------------------------------------------------------
+func mysqlDefault(engine string, dataType string, columnDefault string, extra string) string {
+	if columnDefault == "" {
+		return ""
+	}
+
+	if engine == DbTypeMariadb {
+		if columnDefault == "NULL" {
+			return ""
+		}
+		return columnDefault
+	}
+
+	if strings.HasPrefix(strings.ToUpper(columnDefault), "CURRENT_TIMESTAMP") {
+		return columnDefault
+	}
+
+	if strings.Contains(extra, "DEFAULT_GENERATED") {
+		return "(" + columnDefault + ")"
+	}
+
+	switch dataType {
+	case "tinyint", "smallint", "mediumint", "int", "bigint", "decimal", "float", "double", "bit", "year":
+		return columnDefault
+	}
+
+	return "'" + strings.ReplaceAll(columnDefault, "'", "''") + "'"
+}
+
+// -----------------------------------------------------------------------------
+// mysqlFieldTags
+//
+// Returns the tags of a field from its EXTRA information, generation
+// expression and charset/collation, e.g.:
+//   - auto_increment
+//   - DEFAULT_GENERATED on update CURRENT_TIMESTAMP
+//   - STORED GENERATED
+// -----------------------------------------------------------------------------
+func mysqlFieldTags(
+	extra string,
+	expression string,
+	charset string,
+	collation string,
+	tableCollation string,
+) DbTags {
+	tags := DbTags{}
+	lowerExtra := strings.ToLower(extra)
+
+	if strings.Contains(lowerExtra, "auto_increment") {
+		tags = tags.Add(DbTag{Name: "auto_increment", Value: ""})
+	}
+
+	if i := strings.Index(lowerExtra, "on update "); i >= 0 {
+		tags = tags.Add(DbTag{Name: "on_update", Value: extra[i+len("on update "):]})
+	}
+
+	if strings.Contains(lowerExtra, "virtual generated") {
+		tags = tags.Add(DbTag{Name: "virtual", Value: expression})
+	} else if strings.Contains(lowerExtra, "stored generated") || strings.Contains(lowerExtra, "persistent generated") {
+		tags = tags.Add(DbTag{Name: "stored", Value: expression})
+	}
+
+	if collation != "" && tableCollation != "" && collation != tableCollation {
+		tags = tags.Add(
+			DbTag{Name: "charset", Value: charset},
+			DbTag{Name: "collation", Value: collation},
+		)
+	}
+
+	return tags
+}
+
 // -----------------------------------------------------------------------------
 // fetchMysqlColumnInfo
 // -----------------------------------------------------------------------------
-func (conn *DbConnection) fetchMysqlColumnInfo(dbLayout *DbLayout) error {
+func (conn *DbConnection) fetchMysqlColumnInfo(ctx context.Context, dbLayout *DbLayout) error {
 	type MyColumnDef struct {
-		TableName     string `db:"TABLE_NAME"`
-		ColumnName    string `db:"COLUMN_NAME"`
-		IsNullable    string `db:"IS_NULLABLE"` // YES | NO
-		ColumnType    string `db:"COLUMN_TYPE"`
-		MaxLength     uint32 `db:"MAX_LENGTH"`
-		ColumnComment string `db:"COLUMN_COMMENT"`
-		ColumnDefault string `db:"COLUMN_DEFAULT"` // Default value
+		TableName      string `db:"TABLE_NAME"`
+		ColumnName     string `db:"COLUMN_NAME"`
+		IsNullable     string `db:"IS_NULLABLE"` // YES | NO
+		DataType       string `db:"DATA_TYPE"`
+		ColumnType     string `db:"COLUMN_TYPE"`
+		ColumnKey      string `db:"COLUMN_KEY"` // PRI | UNI | MUL
+		MaxLength      uint32 `db:"MAX_LENGTH"`
+		ColumnComment  string `db:"COLUMN_COMMENT"`
+		ColumnDefault  string `db:"COLUMN_DEFAULT"` // Default value
+		Extra          string `db:"EXTRA"`
+		Expression     string `db:"GENERATION_EXPRESSION"`
+		Charset        string `db:"CHARACTER_SET_NAME"`
+		Collation      string `db:"COLLATION_NAME"`
+		TableCollation string `db:"TABLE_COLLATION"`
 	}
 
 	dbFields := []MyColumnDef{}
 
 	// schema in MYSQL refers to database, whereas we keep postgres definition
-	ctx := context.Background()
-	err := sqlscan.Select(
+	err := conn.SelectContext(
 		ctx,
-		conn.db,
 		&dbFields,
-		`SELECT TABLE_NAME,
-		        COLUMN_NAME,
-		        COALESCE(COLUMN_DEFAULT, '') as COLUMN_DEFAULT,
-		        IS_NULLABLE,
-		        COALESCE(CHARACTER_MAXIMUM_LENGTH, 0) as MAX_LENGTH,
-		        COLUMN_TYPE,
-		        COLUMN_COMMENT
-		   FROM INFORMATION_SCHEMA.COLUMNS
-  	  WHERE table_schema=?
+		`SELECT c.TABLE_NAME,
+		        c.COLUMN_NAME,
+		        COALESCE(c.COLUMN_DEFAULT, '') as COLUMN_DEFAULT,
+		        c.IS_NULLABLE,
+		        COALESCE(c.CHARACTER_MAXIMUM_LENGTH, 0) as MAX_LENGTH,
+		        c.DATA_TYPE,
+		        c.COLUMN_TYPE,
+		        c.COLUMN_KEY,
+		        c.COLUMN_COMMENT,
+		        c.EXTRA,
+		        COALESCE(c.GENERATION_EXPRESSION, '') as GENERATION_EXPRESSION,
+		        COALESCE(c.CHARACTER_SET_NAME, '') as CHARACTER_SET_NAME,
+		        COALESCE(c.COLLATION_NAME, '') as COLLATION_NAME,
+		        COALESCE(t.TABLE_COLLATION, '') as TABLE_COLLATION
+		   FROM INFORMATION_SCHEMA.COLUMNS c
+		   LEFT JOIN INFORMATION_SCHEMA.TABLES t
+		     ON (t.TABLE_SCHEMA = c.TABLE_SCHEMA AND t.TABLE_NAME = c.TABLE_NAME)
+  	  WHERE c.TABLE_SCHEMA=?
   	`,
 		conn.dbName,
 	)
@@ -70,15 +208,26 @@ func (conn *DbConnection) fetchMysqlColumnInfo(dbLayout *DbLayout) error {
 		field := NewDbFieldLayout(dbField.ColumnName)
 		field.Type = dbField.ColumnType
 		field.IsNullable = dbField.IsNullable == "YES"
+		field.IsPrimaryKey = dbField.ColumnKey == "PRI"
+		field.IsUnique = dbField.ColumnKey == "UNI"
 		field.Comment = dbField.ColumnComment
+		field.Default = mysqlDefault(
+			dbLayout.Type,
+			dbField.DataType,
+			dbField.ColumnDefault,
+			dbField.Extra,
+		)
+		field.Tags = mysqlFieldTags(
+			dbField.Extra,
+			dbField.Expression,
+			dbField.Charset,
+			dbField.Collation,
+			dbField.TableCollation,
+		)
 
 		// types already have length in the type itself
 		field.Length = 0
 
-		// TODO: field.IsPrimaryKey
-		// TODO: field.IsUnique
-		// TODO: field.Default
-
 		err := dbLayout.AddField(
 			NoDbSchemaLayoutName,
 			dbField.TableName,
@@ -95,23 +244,30 @@ func (conn *DbConnection) fetchMysqlColumnInfo(dbLayout *DbLayout) error {
 
 // -----------------------------------------------------------------------------
 // fetchMysqlTableInfo
+//
+// Views have no engine, row format nor collation, and MySQL reports "VIEW" as
+// their comment
 // -----------------------------------------------------------------------------
-func (conn *DbConnection) fetchMysqlTableInfo(dbLayout *DbLayout) error {
+func (conn *DbConnection) fetchMysqlTableInfo(ctx context.Context, dbLayout *DbLayout) error {
 	type MyTableDef struct {
 		TableName string `db:"TABLE_NAME"`
 		Comment   string `db:"TABLE_COMMENT"`
+		Engine    string `db:"ENGINE"`
+		RowFormat string `db:"ROW_FORMAT"`
+		Collation string `db:"TABLE_COLLATION"`
 	}
 
 	tableDefList := []MyTableDef{}
 
 	// schema in MYSQL refers to database, whereas we keep postgres definition
-	ctx := context.Background()
-	err := sqlscan.Select(
+	err := conn.SelectContext(
 		ctx,
-		conn.db,
 		&tableDefList,
 		`SELECT TABLE_NAME,
-		        TABLE_COMMENT
+		        TABLE_COMMENT,
+		        COALESCE(ENGINE, '') as ENGINE,
+		        COALESCE(ROW_FORMAT, '') as ROW_FORMAT,
+		        COALESCE(TABLE_COLLATION, '') as TABLE_COLLATION
 
 		   FROM INFORMATION_SCHEMA.TABLES
 		  WHERE table_schema=?
@@ -124,9 +280,122 @@ func (conn *DbConnection) fetchMysqlTableInfo(dbLayout *DbLayout) error {
 
 	for _, tableDef := range tableDefList {
 		table := dbLayout.GetTable(NoDbSchemaLayoutName, tableDef.TableName)
-		if table != nil {
+		if table == nil {
+			continue
+		}
+
+		if tableDef.Engine != "" || tableDef.Comment != "VIEW" {
 			table.Comment = tableDef.Comment
 		}
+
+		metadata := []DbTag{
+			DbTag{Name: "engine", Value: tableDef.Engine},
+			DbTag{Name: "row_format", Value: tableDef.RowFormat},
+			DbTag{Name: "collation", Value: tableDef.Collation},
+		}
+		for _, tag := range metadata {
+			if tag.Value != "" {
+				table.Tags = table.Tags.Add(tag)
+			}
+		}
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// fetchMysqlTriggers
+//
+// MySQL triggers run a statement of their own rather than a function, so
+// there is no function to document, and they have no comment. There are no
+// sequences nor policies either. Triggers of tables whose columns were not
+// read are skipped, so no empty table is added to the layout.
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) fetchMysqlTriggers(ctx context.Context, dbLayout *DbLayout) error {
+	type MyTrigger struct {
+		TableName   string `db:"EVENT_OBJECT_TABLE"`
+		TriggerName string `db:"TRIGGER_NAME"`
+		Timing      string `db:"ACTION_TIMING"`
+		Event       string `db:"EVENT_MANIPULATION"`
+		Level       string `db:"ACTION_ORIENTATION"`
+	}
+
+	triggerList := []MyTrigger{}
+
+	err := conn.SelectContext(
+		ctx,
+		&triggerList,
+		`SELECT EVENT_OBJECT_TABLE,
+		        TRIGGER_NAME,
+		        ACTION_TIMING,
+		        EVENT_MANIPULATION,
+		        ACTION_ORIENTATION
+		   FROM INFORMATION_SCHEMA.TRIGGERS
+		  WHERE TRIGGER_SCHEMA=?
+		`,
+		conn.dbName,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, myTrigger := range triggerList {
+		table := dbLayout.LookupTable(NoDbSchemaLayoutName, myTrigger.TableName)
+		if table == nil {
+			continue
+		}
+
+		trigger := NewDbTriggerLayout(myTrigger.TriggerName)
+		trigger.Timing = myTrigger.Timing
+		trigger.Events = []string{myTrigger.Event}
+		trigger.Level = myTrigger.Level
+
+		table.Triggers = append(table.Triggers, &trigger)
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// fetchMysqlTableStats
+//
+// Rows are estimated by InnoDB, and MySQL does not tell when tables were
+// analyzed
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) fetchMysqlTableStats(ctx context.Context, dbLayout *DbLayout) error {
+	type MyTableStats struct {
+		TableName string `db:"TABLE_NAME"`
+		Rows      int64  `db:"TABLE_ROWS"`
+		Size      int64  `db:"TABLE_SIZE"`
+	}
+
+	tableStatsList := []MyTableStats{}
+
+	err := conn.SelectContext(
+		ctx,
+		&tableStatsList,
+		`SELECT TABLE_NAME,
+		        COALESCE(TABLE_ROWS, -1) as TABLE_ROWS,
+		        COALESCE(DATA_LENGTH + INDEX_LENGTH, -1) as TABLE_SIZE
+		   FROM INFORMATION_SCHEMA.TABLES
+		  WHERE TABLE_SCHEMA=?
+		    AND TABLE_TYPE='BASE TABLE'
+		`,
+		conn.dbName,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, tableStats := range tableStatsList {
+		table := dbLayout.LookupTable(NoDbSchemaLayoutName, tableStats.TableName)
+		if table != nil {
+			table.Stats = &DbTableStats{
+				Rows:         tableStats.Rows,
+				Size:         tableStats.Size,
+				LastAnalyzed: "",
+			}
+		}
 	}
 
 	return nil
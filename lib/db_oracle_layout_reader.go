@@ -0,0 +1,419 @@
+// Copyright (C) 2021 Pau Sanchez
+package lib
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Oracle owners are documented as schemas, skipping the ones maintained by
+// Oracle itself (SYS, SYSTEM, XDB...). Column names are returned in upper case
+// by Oracle, so that's how they are named on the structs below.
+
+const oracleOwnersQuery = `(SELECT username FROM all_users WHERE oracle_maintained = 'N')`
+
+// -----------------------------------------------------------------------------
+// getOracleDbLayout
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) getOracleDbLayout(ctx context.Context) (*DbLayout, error) {
+	dbLayout := NewDbLayout(conn.dbName)
+	dbLayout.Type = DbTypeOracle
+
+	conn.LogProgress("Reading columns")
+	err := conn.fetchOracleColumnInfo(ctx, &dbLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.LogProgress("Reading table comments")
+	err = conn.fetchOracleTableComments(ctx, &dbLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.LogProgress("Reading column comments")
+	err = conn.fetchOracleColumnComments(ctx, &dbLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.LogProgress("Reading keys")
+	err = conn.fetchOracleKeys(ctx, &dbLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.LogProgress("Reading foreign keys")
+	err = conn.fetchOracleForeignKeys(ctx, &dbLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	if conn.withStats {
+		conn.LogProgress("Reading table statistics")
+		err = conn.fetchOracleTableStats(ctx, &dbLayout)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &dbLayout, nil
+}
+
+// -----------------------------------------------------------------------------
+// oracleType
+//
+// Returns the type as it would be written on a CREATE TABLE, since precision,
+// scale and length are on their own columns. Scale is negative when unknown.
+// -----------------------------------------------------------------------------
+func oracleType(dataType string, charLength int64, dataLength int64, precision int64, scale int64) string {
+	switch dataType {
+	case "VARCHAR2", "NVARCHAR2", "CHAR", "NCHAR":
+		return fmt.Sprintf("%s(%d)", dataType, charLength)
+
+	case "RAW":
+		return fmt.Sprintf("%s(%d)", dataType, dataLength)
+
+	case "NUMBER":
+		switch {
+		case precision > 0 && scale > 0:
+			return fmt.Sprintf("NUMBER(%d,%d)", precision, scale)
+		case precision > 0:
+			return fmt.Sprintf("NUMBER(%d)", precision)
+		case scale == 0:
+			return "INTEGER"
+		}
+
+	case "FLOAT":
+		if precision > 0 {
+			return fmt.Sprintf("FLOAT(%d)", precision)
+		}
+	}
+
+	// e.g. DATE, CLOB or TIMESTAMP(6), which already has its precision
+	return dataType
+}
+
+// -----------------------------------------------------------------------------
+// fetchOracleColumnInfo
+//
+// Columns of both tables and views, skipping dropped tables (recycle bin)
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) fetchOracleColumnInfo(ctx context.Context, dbLayout *DbLayout) error {
+	type OraColumnDef struct {
+		Owner      string         `db:"OWNER"`
+		TableName  string         `db:"TABLE_NAME"`
+		ColumnName string         `db:"COLUMN_NAME"`
+		DataType   string         `db:"DATA_TYPE"`
+		CharLength int64          `db:"CHAR_LENGTH"`
+		DataLength int64          `db:"DATA_LENGTH"`
+		Precision  int64          `db:"DATA_PRECISION"`
+		Scale      int64          `db:"DATA_SCALE"`
+		Nullable   string         `db:"NULLABLE"`     // Y | N
+		Default    sql.NullString `db:"DATA_DEFAULT"` // LONG, cannot be used with NVL
+	}
+
+	oraColumns := []OraColumnDef{}
+
+	err := conn.SelectContext(
+		ctx,
+		&oraColumns,
+		`SELECT owner,
+		        table_name,
+		        column_name,
+		        data_type,
+		        char_length,
+		        data_length,
+		        NVL(data_precision, 0) AS data_precision,
+		        NVL(data_scale, -1) AS data_scale,
+		        nullable,
+		        data_default
+		   FROM all_tab_columns
+		  WHERE owner IN `+oracleOwnersQuery+`
+		    AND table_name NOT LIKE 'BIN$%'
+		  ORDER BY owner, table_name, column_id
+		`,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, oraColumn := range oraColumns {
+		field := NewDbFieldLayout(oraColumn.ColumnName)
+		field.Type = oracleType(
+			oraColumn.DataType,
+			oraColumn.CharLength,
+			oraColumn.DataLength,
+			oraColumn.Precision,
+			oraColumn.Scale,
+		)
+		field.IsNullable = oraColumn.Nullable == "Y"
+		field.Default = strings.TrimSpace(oraColumn.Default.String)
+
+		// types already have length in the type itself
+		field.Length = 0
+
+		err := dbLayout.AddField(
+			oraColumn.Owner,
+			oraColumn.TableName,
+			field,
+		)
+
+		if err != nil {
+			log.Println("Ignoring error:", err)
+		}
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// fetchOracleTableComments
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) fetchOracleTableComments(ctx context.Context, dbLayout *DbLayout) error {
+	type OraTableComment struct {
+		Owner     string `db:"OWNER"`
+		TableName string `db:"TABLE_NAME"`
+		Comments  string `db:"COMMENTS"`
+	}
+
+	oraComments := []OraTableComment{}
+
+	err := conn.SelectContext(
+		ctx,
+		&oraComments,
+		`SELECT owner, table_name, comments
+		   FROM all_tab_comments
+		  WHERE owner IN `+oracleOwnersQuery+`
+		    AND comments IS NOT NULL
+		`,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, comment := range oraComments {
+		table := dbLayout.GetTable(comment.Owner, comment.TableName)
+		if table != nil {
+			table.Comment = comment.Comments
+		}
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// fetchOracleColumnComments
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) fetchOracleColumnComments(ctx context.Context, dbLayout *DbLayout) error {
+	type OraColumnComment struct {
+		Owner      string `db:"OWNER"`
+		TableName  string `db:"TABLE_NAME"`
+		ColumnName string `db:"COLUMN_NAME"`
+		Comments   string `db:"COMMENTS"`
+	}
+
+	oraComments := []OraColumnComment{}
+
+	err := conn.SelectContext(
+		ctx,
+		&oraComments,
+		`SELECT owner, table_name, column_name, comments
+		   FROM all_col_comments
+		  WHERE owner IN `+oracleOwnersQuery+`
+		    AND comments IS NOT NULL
+		`,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, comment := range oraComments {
+		field := dbLayout.GetField(comment.Owner, comment.TableName, comment.ColumnName)
+		if field != nil {
+			field.Comment = comment.Comments
+		}
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// fetchOracleKeys
+//
+// Primary keys and single column unique constraints
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) fetchOracleKeys(ctx context.Context, dbLayout *DbLayout) error {
+	type OraKeyColumn struct {
+		Owner          string `db:"OWNER"`
+		TableName      string `db:"TABLE_NAME"`
+		ColumnName     string `db:"COLUMN_NAME"`
+		ConstraintType string `db:"CONSTRAINT_TYPE"` // P | U
+		ColumnCount    int64  `db:"COLUMN_COUNT"`
+	}
+
+	oraKeyColumns := []OraKeyColumn{}
+
+	err := conn.SelectContext(
+		ctx,
+		&oraKeyColumns,
+		`SELECT c.owner,
+		        c.table_name,
+		        cc.column_name,
+		        c.constraint_type,
+		        COUNT(*) OVER (PARTITION BY c.owner, c.constraint_name) AS column_count
+		   FROM all_constraints c
+		  INNER JOIN all_cons_columns cc
+		     ON (cc.owner = c.owner AND cc.constraint_name = c.constraint_name)
+		  WHERE c.owner IN `+oracleOwnersQuery+`
+		    AND c.constraint_type IN ('P', 'U')
+		`,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, keyColumn := range oraKeyColumns {
+		field := dbLayout.GetField(keyColumn.Owner, keyColumn.TableName, keyColumn.ColumnName)
+		if field == nil {
+			continue
+		}
+
+		if keyColumn.ConstraintType == "P" {
+			field.IsPrimaryKey = true
+		} else if keyColumn.ColumnCount == 1 {
+			field.IsUnique = true
+		}
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// fetchOracleForeignKeys
+//
+// Columns of both sides are matched by their position on the constraint
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) fetchOracleForeignKeys(ctx context.Context, dbLayout *DbLayout) error {
+	type OraForeignKeyColumn struct {
+		ConstraintName string `db:"CONSTRAINT_NAME"`
+		Owner          string `db:"OWNER"`
+		TableName      string `db:"TABLE_NAME"`
+		ColumnName     string `db:"COLUMN_NAME"`
+		RefOwner       string `db:"R_OWNER"`
+		RefTableName   string `db:"R_TABLE_NAME"`
+		RefColumnName  string `db:"R_COLUMN_NAME"`
+	}
+
+	oraForeignKeyColumns := []OraForeignKeyColumn{}
+
+	err := conn.SelectContext(
+		ctx,
+		&oraForeignKeyColumns,
+		`SELECT c.constraint_name,
+		        c.owner,
+		        c.table_name,
+		        cc.column_name,
+		        r.owner AS r_owner,
+		        r.table_name AS r_table_name,
+		        rcc.column_name AS r_column_name
+		   FROM all_constraints c
+		  INNER JOIN all_cons_columns cc
+		     ON (cc.owner = c.owner AND cc.constraint_name = c.constraint_name)
+		  INNER JOIN all_constraints r
+		     ON (r.owner = c.r_owner AND r.constraint_name = c.r_constraint_name)
+		  INNER JOIN all_cons_columns rcc
+		     ON (rcc.owner = r.owner AND rcc.constraint_name = r.constraint_name AND rcc.position = cc.position)
+		  WHERE c.owner IN `+oracleOwnersQuery+`
+		    AND c.constraint_type = 'R'
+		  ORDER BY c.owner, c.table_name, c.constraint_name, cc.position
+		`,
+	)
+	if err != nil {
+		return err
+	}
+
+	var ref *DbRefLayout
+	lastConstraint := ""
+	for _, fkColumn := range oraForeignKeyColumns {
+		constraint := fkColumn.Owner + "." + fkColumn.ConstraintName
+		if ref == nil || constraint != lastConstraint {
+			lastConstraint = constraint
+
+			// names generated by Oracle (SYS_C...) are not worth documenting
+			name := fkColumn.ConstraintName
+			if strings.HasPrefix(name, "SYS_C") {
+				name = ""
+			}
+
+			ref = &DbRefLayout{
+				Name:       name,
+				FromSchema: fkColumn.Owner,
+				FromTable:  fkColumn.TableName,
+				FromFields: []string{},
+				Relation:   ">",
+				ToSchema:   fkColumn.RefOwner,
+				ToTable:    fkColumn.RefTableName,
+				ToFields:   []string{},
+				Settings:   "",
+			}
+			dbLayout.Refs = append(dbLayout.Refs, ref)
+		}
+
+		ref.FromFields = append(ref.FromFields, fkColumn.ColumnName)
+		ref.ToFields = append(ref.ToFields, fkColumn.RefColumnName)
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// fetchOracleTableStats
+//
+// Rows are the ones counted the last time the table was analyzed (e.g. with
+// DBMS_STATS), sizes are not read since segments are only visible to DBAs
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) fetchOracleTableStats(ctx context.Context, dbLayout *DbLayout) error {
+	type OraTableStats struct {
+		Owner        string `db:"OWNER"`
+		TableName    string `db:"TABLE_NAME"`
+		NumRows      int64  `db:"NUM_ROWS"`
+		LastAnalyzed string `db:"LAST_ANALYZED"`
+	}
+
+	oraTableStats := []OraTableStats{}
+
+	err := conn.SelectContext(
+		ctx,
+		&oraTableStats,
+		`SELECT owner,
+		        table_name,
+		        NVL(num_rows, -1) AS num_rows,
+		        NVL(TO_CHAR(last_analyzed, 'YYYY-MM-DD HH24:MI:SS'), ' ') AS last_analyzed
+		   FROM all_tables
+		  WHERE owner IN `+oracleOwnersQuery+`
+		    AND table_name NOT LIKE 'BIN$%'
+		`,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, oraStats := range oraTableStats {
+		stats := DbTableStats{
+			Rows:         oraStats.NumRows,
+			Size:         -1,
+			LastAnalyzed: strings.TrimSpace(oraStats.LastAnalyzed),
+		}
+
+		table := dbLayout.LookupTable(oraStats.Owner, oraStats.TableName)
+		if table != nil && !stats.IsEmpty() {
+			table.Stats = &stats
+		}
+	}
+
+	return nil
+}
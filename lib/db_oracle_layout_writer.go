@@ -0,0 +1,60 @@
+// Copyright (C) 2021 Pau Sanchez
+package lib
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+// oracleQuoteIdentifier
+// -----------------------------------------------------------------------------
+func oracleQuoteIdentifier(name string) string {
+	return "\"" + strings.ReplaceAll(name, "\"", "\"\"") + "\""
+}
+
+// -----------------------------------------------------------------------------
+// oracleQuoteLiteral
+//
+// COMMENT ON does not accept bind variables, so the comment is quoted instead.
+// Oracle removes comments set to an empty string.
+// -----------------------------------------------------------------------------
+func oracleQuoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// -----------------------------------------------------------------------------
+// syncOracleDbLayout
+//
+// Write comments and tags of given items with COMMENT ON. These are DDL
+// statements, committed one by one by Oracle itself, so an error might leave
+// the items before it already updated.
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) syncOracleDbLayout(ctx context.Context, items []DbSyncItem) error {
+	for _, item := range items {
+		relation := oracleQuoteIdentifier(item.Schema) + "." + oracleQuoteIdentifier(item.Table)
+		comment := oracleQuoteLiteral(commentWithTrailingTags(item.Comment, item.Tags))
+
+		// COMMENT ON TABLE works for views as well
+		var query string
+		if item.Field != "" {
+			query = fmt.Sprintf(
+				"COMMENT ON COLUMN %s.%s IS %s",
+				relation, oracleQuoteIdentifier(item.Field), comment,
+			)
+		} else {
+			query = fmt.Sprintf("COMMENT ON TABLE %s IS %s", relation, comment)
+		}
+
+		queryCtx, cancel := conn.QueryContext(ctx)
+		_, err := conn.db.ExecContext(queryCtx, query)
+		cancel()
+
+		if err != nil {
+			return fmt.Errorf("cannot update %s: %s", item, err)
+		}
+	}
+
+	return nil
+}
@@ -4,43 +4,104 @@ package lib
 import (
 	"context"
 	"log"
-
-	"github.com/georgysavva/scany/sqlscan"
+	"strings"
 )
 
+// Types are read with format_type, as they would be written on a CREATE TABLE
+// (e.g. character varying(64), integer[] or numeric(10,2)), and identity,
+// generated and serial columns, along with explicit collations, as tags:
+//   - @identity:always or @identity:"by default"
+//   - @stored:"(price * quantity)"
+//   - @serial:public.user_id_seq
+//   - @collation:C
+const postgresColumnsSelect = `
+	SELECT c.table_schema,
+	       c.table_name,
+	       c.column_name,
+	       c.is_nullable,
+	       format_type(a.atttypid, a.atttypmod) as type_name,
+	       c.is_identity,
+	       COALESCE(c.identity_generation, '') as identity_generation,
+	       c.is_generated,
+	       COALESCE(c.generation_expression, '') as generation_expression,
+	       CASE WHEN c.is_identity = 'NO' AND c.column_default LIKE 'nextval(%'
+	            THEN COALESCE(pg_get_serial_sequence(quote_ident(c.table_schema) || '.' || quote_ident(c.table_name), c.column_name), '')
+	            ELSE ''
+	       END as serial_sequence,
+	       COALESCE(c.collation_name, '') as collation_name
+	  FROM information_schema.columns c
+	 INNER JOIN pg_catalog.pg_namespace n ON (n.nspname = c.table_schema)
+	 INNER JOIN pg_catalog.pg_class r ON (r.relnamespace = n.oid AND r.relname = c.table_name)
+	 INNER JOIN pg_catalog.pg_attribute a ON (a.attrelid = r.oid AND a.attname = c.column_name)
+`
+
+const postgresColumnsQuery = postgresColumnsSelect + `
+	 WHERE c.table_schema not in ('information_schema', 'pg_catalog')
+`
+
+// -----------------------------------------------------------------------------
+// getPostgresEngine
+//
+// CockroachDB and YugabyteDB speak the postgres protocol as well, but their
+// catalogs differ, so the engine is told apart by its version string:
+//   - CockroachDB CCL v23.1.11 (x86_64-pc-linux-gnu, ...)
+//   - PostgreSQL 11.2-YB-2.18.0.0-b0 on x86_64-pc-linux-gnu, ...
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) getPostgresEngine(ctx context.Context) (string, error) {
+	var versions []string
+
+	err := conn.SelectContext(ctx, &versions, `SELECT version()`)
+	if err != nil {
+		return "", err
+	}
+
+	if len(versions) > 0 {
+		switch {
+		case strings.Contains(versions[0], "CockroachDB"):
+			return DbTypeCockroach, nil
+		case strings.Contains(versions[0], "-YB-"):
+			return DbTypeYugabyte, nil
+		}
+	}
+
+	return DbTypePostgres, nil
+}
+
 // -----------------------------------------------------------------------------
 // getPostgresDbLayout
 // -----------------------------------------------------------------------------
-func (conn *DbConnection) getPostgresDbLayout() (*DbLayout, error) {
+func (conn *DbConnection) getPostgresDbLayout(ctx context.Context) (*DbLayout, error) {
+	engine, err := conn.getPostgresEngine(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	dbLayout := NewDbLayout(conn.dbName)
-	dbLayout.Type = DbTypePostgres
+	dbLayout.Type = engine
 
 	type PgFieldSchema struct {
-		TableSchema            string
-		TableName              string
-		ColumnName             string
-		IsNullable             string // YES | NO
-		TypeName               string // varchar | timestamp | uuid | int2 | int8 | ...
-		CharacterMaximumLength uint32
+		TableSchema          string
+		TableName            string
+		ColumnName           string
+		IsNullable           string // YES | NO
+		TypeName             string // character varying(64) | integer[] | uuid | ...
+		IsIdentity           string // YES | NO
+		IdentityGeneration   string // ALWAYS | BY DEFAULT
+		IsGenerated          string // ALWAYS | NEVER
+		GenerationExpression string
+		SerialSequence       string
+		CollationName        string
 	}
 
 	pgFields := []PgFieldSchema{}
 
-	ctx := context.Background()
-	err := sqlscan.Select(
-		ctx,
-		conn.db,
-		&pgFields,
-		`SELECT table_schema,
-		        table_name,
-		        column_name,
-		        is_nullable,
-		        udt_name as type_name,
-		        COALESCE(character_maximum_length, 0) as character_maximum_length
-       FROM information_schema.columns
-      WHERE table_schema not in ('information_schema', 'pg_catalog')
-		`,
-	)
+	columnsQuery := postgresColumnsQuery
+	if engine == DbTypeCockroach {
+		columnsQuery = cockroachColumnsQuery
+	}
+
+	conn.LogProgress("Reading columns")
+	err = conn.SelectContext(ctx, &pgFields, columnsQuery)
 	if err != nil {
 		return nil, err
 	}
@@ -49,7 +110,22 @@ func (conn *DbConnection) getPostgresDbLayout() (*DbLayout, error) {
 		field := NewDbFieldLayout(pgField.ColumnName)
 		field.Type = pgField.TypeName
 		field.IsNullable = pgField.IsNullable == "YES"
-		field.Length = pgField.CharacterMaximumLength
+
+		// types already have length in the type itself
+		field.Length = 0
+
+		if pgField.IsIdentity == "YES" {
+			field.Tags = field.Tags.Add(DbTag{Name: "identity", Value: strings.ToLower(pgField.IdentityGeneration)})
+		}
+		if pgField.IsGenerated == "ALWAYS" {
+			field.Tags = field.Tags.Add(DbTag{Name: "stored", Value: pgField.GenerationExpression})
+		}
+		if pgField.SerialSequence != "" {
+			field.Tags = field.Tags.Add(DbTag{Name: "serial", Value: pgField.SerialSequence})
+		}
+		if pgField.CollationName != "" {
+			field.Tags = field.Tags.Add(DbTag{Name: "collation", Value: pgField.CollationName})
+		}
 
 		// TODO: field.IsPrimaryKey
 		// TODO: field.IsUnique
@@ -67,36 +143,60 @@ func (conn *DbConnection) getPostgresDbLayout() (*DbLayout, error) {
 	}
 
 	// field.Comment will be updated here
-	err = conn.getPostgresDbComments(&dbLayout)
+	conn.LogProgress("Reading comments")
+	err = conn.getPostgresDbComments(ctx, &dbLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.LogProgress("Reading sequences, triggers and policies")
+	err = conn.getPostgresDbObjects(ctx, &dbLayout)
 	if err != nil {
 		return nil, err
 	}
 
+	if conn.withStats {
+		conn.LogProgress("Reading table statistics")
+		err = conn.getPostgresDbTableStats(ctx, &dbLayout)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &dbLayout, nil
 }
 
 // -----------------------------------------------------------------------------
 // getPostgresDbComments
 // -----------------------------------------------------------------------------
-func (conn *DbConnection) getPostgresDbComments(dbLayout *DbLayout) error {
+func (conn *DbConnection) getPostgresDbComments(ctx context.Context, dbLayout *DbLayout) error {
 	var err error
 
-	err = conn.getPostgresDatabaseComment(dbLayout)
+	if dbLayout.Type == DbTypeCockroach {
+		return conn.getCockroachDbComments(ctx, dbLayout)
+	}
+
+	err = conn.getPostgresDatabaseComment(ctx, dbLayout)
 	if err != nil {
 		return err
 	}
 
-	err = conn.getPostgresDbSchemaComments(dbLayout)
+	err = conn.getPostgresDbSchemaComments(ctx, dbLayout)
 	if err != nil {
 		return err
 	}
 
-	err = conn.getPostgresDbTableComments(dbLayout)
+	err = conn.getPostgresDbTableComments(ctx, dbLayout)
 	if err != nil {
 		return err
 	}
 
-	err = conn.getPostgresDbColumnComments(dbLayout)
+	err = conn.getPostgresDbColumnComments(ctx, dbLayout)
+	if err != nil {
+		return err
+	}
+
+	err = conn.getPostgresDbSecurityLabels(ctx, dbLayout)
 	if err != nil {
 		return err
 	}
@@ -107,13 +207,11 @@ func (conn *DbConnection) getPostgresDbComments(dbLayout *DbLayout) error {
 // -----------------------------------------------------------------------------
 // getPostgresDatabaseComment
 // -----------------------------------------------------------------------------
-func (conn *DbConnection) getPostgresDatabaseComment(dbLayout *DbLayout) error {
+func (conn *DbConnection) getPostgresDatabaseComment(ctx context.Context, dbLayout *DbLayout) error {
 	var comments []string
 
-	ctx := context.Background()
-	err := sqlscan.Select(
+	err := conn.SelectContext(
 		ctx,
-		conn.db,
 		&comments,
 		`SELECT COALESCE(description, '') as comment
 		   FROM pg_shdescription
@@ -137,7 +235,7 @@ func (conn *DbConnection) getPostgresDatabaseComment(dbLayout *DbLayout) error {
 // -----------------------------------------------------------------------------
 // getPostgresDbSchemaComments
 // -----------------------------------------------------------------------------
-func (conn *DbConnection) getPostgresDbSchemaComments(dbLayout *DbLayout) error {
+func (conn *DbConnection) getPostgresDbSchemaComments(ctx context.Context, dbLayout *DbLayout) error {
 	type SchemaComment struct {
 		SchemaName string
 		Comment    string
@@ -145,10 +243,8 @@ func (conn *DbConnection) getPostgresDbSchemaComments(dbLayout *DbLayout) error
 
 	pgComments := []SchemaComment{}
 
-	ctx := context.Background()
-	err := sqlscan.Select(
+	err := conn.SelectContext(
 		ctx,
-		conn.db,
 		&pgComments,
 		`SELECT schema_name,
 		        COALESCE(obj_description(schema_name::regnamespace, 'pg_namespace'), '') AS comment
@@ -175,7 +271,7 @@ func (conn *DbConnection) getPostgresDbSchemaComments(dbLayout *DbLayout) error
 // -----------------------------------------------------------------------------
 // getPostgresDbTableComments
 // -----------------------------------------------------------------------------
-func (conn *DbConnection) getPostgresDbTableComments(dbLayout *DbLayout) error {
+func (conn *DbConnection) getPostgresDbTableComments(ctx context.Context, dbLayout *DbLayout) error {
 	type TableComment struct {
 		TableSchema string
 		TableName   string
@@ -184,10 +280,8 @@ func (conn *DbConnection) getPostgresDbTableComments(dbLayout *DbLayout) error {
 
 	pgComments := []TableComment{}
 
-	ctx := context.Background()
-	err := sqlscan.Select(
+	err := conn.SelectContext(
 		ctx,
-		conn.db,
 		&pgComments,
 		`SELECT table_schema,
 		        table_name,
@@ -215,7 +309,7 @@ func (conn *DbConnection) getPostgresDbTableComments(dbLayout *DbLayout) error {
 // -----------------------------------------------------------------------------
 // getPostgresDbColumnComments
 // -----------------------------------------------------------------------------
-func (conn *DbConnection) getPostgresDbColumnComments(dbLayout *DbLayout) error {
+func (conn *DbConnection) getPostgresDbColumnComments(ctx context.Context, dbLayout *DbLayout) error {
 	type ColumnComment struct {
 		TableSchema string
 		TableName   string
@@ -225,10 +319,8 @@ func (conn *DbConnection) getPostgresDbColumnComments(dbLayout *DbLayout) error
 
 	pgComments := []ColumnComment{}
 
-	ctx := context.Background()
-	err := sqlscan.Select(
+	err := conn.SelectContext(
 		ctx,
-		conn.db,
 		&pgComments,
 		`SELECT c.table_schema, c.table_name, c.column_name, pgd.description as comment
        FROM pg_catalog.pg_statio_all_tables as st
@@ -255,3 +347,368 @@ func (conn *DbConnection) getPostgresDbColumnComments(dbLayout *DbLayout) error
 
 	return nil
 }
+
+// -----------------------------------------------------------------------------
+// getPostgresDbSecurityLabels
+//
+// Security labels of tables and columns are read as tags. A label written as
+// tags (e.g. "@classification:confidential") is taken as it is, any other
+// label becomes a tag named after its provider.
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) getPostgresDbSecurityLabels(ctx context.Context, dbLayout *DbLayout) error {
+	type SecurityLabel struct {
+		TableSchema string
+		TableName   string
+		ColumnName  string
+		Provider    string
+		Label       string
+	}
+
+	pgLabels := []SecurityLabel{}
+
+	err := conn.SelectContext(
+		ctx,
+		&pgLabels,
+		`SELECT n.nspname as table_schema,
+		        c.relname as table_name,
+		        COALESCE(a.attname, '') as column_name,
+		        sl.provider,
+		        sl.label
+       FROM pg_catalog.pg_seclabel sl
+ INNER JOIN pg_catalog.pg_class c
+         ON (sl.classoid = 'pg_catalog.pg_class'::regclass AND sl.objoid = c.oid)
+ INNER JOIN pg_catalog.pg_namespace n
+         ON (n.oid = c.relnamespace)
+  LEFT JOIN pg_catalog.pg_attribute a
+         ON (a.attrelid = c.oid AND a.attnum = sl.objsubid AND sl.objsubid > 0)
+      WHERE n.nspname NOT IN ('pg_catalog', 'information_schema')
+		`,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	for _, pgLabel := range pgLabels {
+		rest, tags := ParseTags(pgLabel.Label)
+		if rest != "" || len(tags) == 0 {
+			tags = DbTags{DbTag{Name: pgLabel.Provider, Value: pgLabel.Label}}
+		}
+
+		if pgLabel.ColumnName == "" {
+			table := dbLayout.GetTable(pgLabel.TableSchema, pgLabel.TableName)
+			if table != nil {
+				table.Tags = table.Tags.Add(tags...)
+			}
+			continue
+		}
+
+		field := dbLayout.GetField(pgLabel.TableSchema, pgLabel.TableName, pgLabel.ColumnName)
+		if field != nil {
+			field.Tags = field.Tags.Add(tags...)
+		}
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// getPostgresDbTableStats
+//
+// Rows are estimated by the planner (reltuples), which is -1 on tables that
+// have never been analyzed since postgres 14. CockroachDB lacks these
+// statistics, so none are read.
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) getPostgresDbTableStats(ctx context.Context, dbLayout *DbLayout) error {
+	if dbLayout.Type == DbTypeCockroach {
+		conn.LogProgress("Table statistics are not available on %s", dbLayout.Type)
+		return nil
+	}
+
+	type TableStats struct {
+		TableSchema  string
+		TableName    string
+		Rows         int64
+		Size         int64
+		LastAnalyzed string
+	}
+
+	pgStats := []TableStats{}
+
+	err := conn.SelectContext(
+		ctx,
+		&pgStats,
+		`SELECT n.nspname as table_schema,
+		        c.relname as table_name,
+		        c.reltuples::bigint as rows,
+		        pg_total_relation_size(c.oid) as size,
+		        COALESCE(to_char(GREATEST(s.last_analyze, s.last_autoanalyze), 'YYYY-MM-DD HH24:MI:SS'), '') as last_analyzed
+		   FROM pg_catalog.pg_class c
+		  INNER JOIN pg_catalog.pg_namespace n ON (n.oid = c.relnamespace)
+		   LEFT JOIN pg_catalog.pg_stat_all_tables s ON (s.relid = c.oid)
+		  WHERE c.relkind IN ('r', 'p', 'm')
+		    AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+		`,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	for _, pgStat := range pgStats {
+		table := dbLayout.LookupTable(pgStat.TableSchema, pgStat.TableName)
+		if table != nil {
+			table.Stats = &DbTableStats{
+				Rows:         pgStat.Rows,
+				Size:         pgStat.Size,
+				LastAnalyzed: pgStat.LastAnalyzed,
+			}
+		}
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// getPostgresDbObjects
+//
+// Sequences, triggers and row level security policies. CockroachDB lacks
+// most of the catalogs needed, so none are read.
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) getPostgresDbObjects(ctx context.Context, dbLayout *DbLayout) error {
+	if dbLayout.Type == DbTypeCockroach {
+		conn.LogProgress("Sequences, triggers and policies are not available on %s", dbLayout.Type)
+		return nil
+	}
+
+	err := conn.getPostgresDbSequences(ctx, dbLayout)
+	if err != nil {
+		return err
+	}
+
+	err = conn.getPostgresDbTriggers(ctx, dbLayout)
+	if err != nil {
+		return err
+	}
+
+	return conn.getPostgresDbPolicies(ctx, dbLayout)
+}
+
+// -----------------------------------------------------------------------------
+// getPostgresDbSequences
+//
+// Sequences backing identity columns are left out, since they are already
+// documented by the @identity tag of the column. Sequences of serial columns
+// are owned by them.
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) getPostgresDbSequences(ctx context.Context, dbLayout *DbLayout) error {
+	type PgSequence struct {
+		SequenceSchema string
+		SequenceName   string
+		TypeName       string
+		Increment      int64
+		OwnedBy        string
+		Comment        string
+	}
+
+	pgSequences := []PgSequence{}
+
+	err := conn.SelectContext(
+		ctx,
+		&pgSequences,
+		`SELECT n.nspname as sequence_schema,
+		        c.relname as sequence_name,
+		        format_type(s.seqtypid, NULL) as type_name,
+		        s.seqincrement as increment,
+		        COALESCE(t.relname || '.' || a.attname, '') as owned_by,
+		        COALESCE(obj_description(c.oid, 'pg_class'), '') as comment
+		   FROM pg_catalog.pg_sequence s
+		  INNER JOIN pg_catalog.pg_class c ON (c.oid = s.seqrelid)
+		  INNER JOIN pg_catalog.pg_namespace n ON (n.oid = c.relnamespace)
+		   LEFT JOIN pg_catalog.pg_depend d
+		          ON (d.classid = 'pg_catalog.pg_class'::regclass
+		              AND d.objid = c.oid
+		              AND d.refclassid = 'pg_catalog.pg_class'::regclass
+		              AND d.deptype IN ('a', 'i'))
+		   LEFT JOIN pg_catalog.pg_class t ON (t.oid = d.refobjid)
+		   LEFT JOIN pg_catalog.pg_attribute a ON (a.attrelid = d.refobjid AND a.attnum = d.refobjsubid)
+		  WHERE (d.deptype IS NULL OR d.deptype = 'a')
+		    AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+		`,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	for _, pgSequence := range pgSequences {
+		sequence := NewDbSequenceLayout(pgSequence.SequenceName)
+		sequence.Type = pgSequence.TypeName
+		sequence.Increment = pgSequence.Increment
+		sequence.OwnedBy = pgSequence.OwnedBy
+		sequence.Comment = pgSequence.Comment
+
+		schema := dbLayout.GetOrCreateSchema(pgSequence.SequenceSchema)
+		schema.Sequences = append(schema.Sequences, &sequence)
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// getPostgresDbTriggers
+//
+// Timing, events and level are encoded as bits of tgtype (see pg_trigger.h)
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) getPostgresDbTriggers(ctx context.Context, dbLayout *DbLayout) error {
+	const (
+		triggerTypeRow      = 1 << 0
+		triggerTypeBefore   = 1 << 1
+		triggerTypeInsert   = 1 << 2
+		triggerTypeDelete   = 1 << 3
+		triggerTypeUpdate   = 1 << 4
+		triggerTypeTruncate = 1 << 5
+		triggerTypeInstead  = 1 << 6
+	)
+
+	type PgTrigger struct {
+		TableSchema  string
+		TableName    string
+		TriggerName  string
+		TriggerType  int64
+		FunctionName string
+		Comment      string
+	}
+
+	pgTriggers := []PgTrigger{}
+
+	err := conn.SelectContext(
+		ctx,
+		&pgTriggers,
+		`SELECT n.nspname as table_schema,
+		        c.relname as table_name,
+		        t.tgname as trigger_name,
+		        t.tgtype as trigger_type,
+		        quote_ident(pn.nspname) || '.' || quote_ident(p.proname) || '()' as function_name,
+		        COALESCE(obj_description(t.oid, 'pg_trigger'), '') as comment
+		   FROM pg_catalog.pg_trigger t
+		  INNER JOIN pg_catalog.pg_class c ON (c.oid = t.tgrelid)
+		  INNER JOIN pg_catalog.pg_namespace n ON (n.oid = c.relnamespace)
+		  INNER JOIN pg_catalog.pg_proc p ON (p.oid = t.tgfoid)
+		  INNER JOIN pg_catalog.pg_namespace pn ON (pn.oid = p.pronamespace)
+		  WHERE NOT t.tgisinternal
+		    AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+		`,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	for _, pgTrigger := range pgTriggers {
+		table := dbLayout.GetTable(pgTrigger.TableSchema, pgTrigger.TableName)
+		if table == nil {
+			continue
+		}
+
+		trigger := NewDbTriggerLayout(pgTrigger.TriggerName)
+		trigger.Function = pgTrigger.FunctionName
+		trigger.Comment = pgTrigger.Comment
+
+		switch {
+		case pgTrigger.TriggerType&triggerTypeInstead != 0:
+			trigger.Timing = "INSTEAD OF"
+		case pgTrigger.TriggerType&triggerTypeBefore != 0:
+			trigger.Timing = "BEFORE"
+		default:
+			trigger.Timing = "AFTER"
+		}
+
+		// same order as pg_get_triggerdef
+		if pgTrigger.TriggerType&triggerTypeInsert != 0 {
+			trigger.Events = append(trigger.Events, "INSERT")
+		}
+		if pgTrigger.TriggerType&triggerTypeDelete != 0 {
+			trigger.Events = append(trigger.Events, "DELETE")
+		}
+		if pgTrigger.TriggerType&triggerTypeUpdate != 0 {
+			trigger.Events = append(trigger.Events, "UPDATE")
+		}
+		if pgTrigger.TriggerType&triggerTypeTruncate != 0 {
+			trigger.Events = append(trigger.Events, "TRUNCATE")
+		}
+
+		trigger.Level = "STATEMENT"
+		if pgTrigger.TriggerType&triggerTypeRow != 0 {
+			trigger.Level = "ROW"
+		}
+
+		table.Triggers = append(table.Triggers, &trigger)
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// getPostgresDbPolicies
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) getPostgresDbPolicies(ctx context.Context, dbLayout *DbLayout) error {
+	type PgPolicy struct {
+		TableSchema string
+		TableName   string
+		PolicyName  string
+		Permissive  string // PERMISSIVE | RESTRICTIVE
+		Command     string // ALL | SELECT | INSERT | UPDATE | DELETE
+		Roles       string
+		UsingExpr   string
+		CheckExpr   string
+		Comment     string
+	}
+
+	pgPolicies := []PgPolicy{}
+
+	err := conn.SelectContext(
+		ctx,
+		&pgPolicies,
+		`SELECT p.schemaname as table_schema,
+		        p.tablename as table_name,
+		        p.policyname as policy_name,
+		        p.permissive,
+		        p.cmd as command,
+		        array_to_string(p.roles, ', ') as roles,
+		        COALESCE(p.qual, '') as using_expr,
+		        COALESCE(p.with_check, '') as check_expr,
+		        COALESCE(obj_description(pp.oid, 'pg_policy'), '') as comment
+		   FROM pg_catalog.pg_policies p
+		  INNER JOIN pg_catalog.pg_namespace n ON (n.nspname = p.schemaname)
+		  INNER JOIN pg_catalog.pg_class c ON (c.relnamespace = n.oid AND c.relname = p.tablename)
+		  INNER JOIN pg_catalog.pg_policy pp ON (pp.polrelid = c.oid AND pp.polname = p.policyname)
+		  WHERE p.schemaname NOT IN ('pg_catalog', 'information_schema')
+		`,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	for _, pgPolicy := range pgPolicies {
+		table := dbLayout.GetTable(pgPolicy.TableSchema, pgPolicy.TableName)
+		if table == nil {
+			continue
+		}
+
+		policy := NewDbPolicyLayout(pgPolicy.PolicyName)
+		policy.IsRestrictive = pgPolicy.Permissive == "RESTRICTIVE"
+		policy.Command = pgPolicy.Command
+		if pgPolicy.Roles != "" {
+			policy.Roles = strings.Split(pgPolicy.Roles, ", ")
+		}
+		policy.Using = singleLine(pgPolicy.UsingExpr)
+		policy.Check = singleLine(pgPolicy.CheckExpr)
+		policy.Comment = pgPolicy.Comment
+
+		table.Policies = append(table.Policies, &policy)
+	}
+
+	return nil
+}
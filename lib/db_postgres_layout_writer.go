@@ -0,0 +1,119 @@
+// Copyright (C) 2021 Pau Sanchez
+package lib
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+// postgresQuoteIdentifier
+// -----------------------------------------------------------------------------
+func postgresQuoteIdentifier(name string) string {
+	return "\"" + strings.ReplaceAll(name, "\"", "\"\"") + "\""
+}
+
+// -----------------------------------------------------------------------------
+// postgresQuoteLiteral
+//
+// COMMENT ON does not accept parameters, so the comment is quoted instead.
+// Empty comments are removed from the database with NULL.
+// -----------------------------------------------------------------------------
+func postgresQuoteLiteral(value string) string {
+	if value == "" {
+		return "NULL"
+	}
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// -----------------------------------------------------------------------------
+// getPostgresRelationKind
+//
+// Returns the kind of relation as expected by COMMENT ON: TABLE, VIEW,
+// MATERIALIZED VIEW or FOREIGN TABLE
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) getPostgresRelationKind(
+	ctx context.Context,
+	tx *sql.Tx,
+	schema string,
+	table string,
+) (
+	string,
+	error,
+) {
+	var relkind string
+
+	ctx, cancel := conn.QueryContext(ctx)
+	defer cancel()
+
+	err := tx.QueryRowContext(
+		ctx,
+		`SELECT c.relkind::text
+       FROM pg_catalog.pg_class c
+ INNER JOIN pg_catalog.pg_namespace n ON (n.oid = c.relnamespace)
+      WHERE n.nspname = $1
+        AND c.relname = $2
+		`,
+		schema,
+		table,
+	).Scan(&relkind)
+
+	if err != nil {
+		return "", err
+	}
+
+	switch relkind {
+	case "v":
+		return "VIEW", nil
+	case "m":
+		return "MATERIALIZED VIEW", nil
+	case "f":
+		return "FOREIGN TABLE", nil
+	}
+
+	return "TABLE", nil
+}
+
+// -----------------------------------------------------------------------------
+// syncPostgresDbLayout
+//
+// Write comments and tags of given items with COMMENT ON, all at once
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) syncPostgresDbLayout(ctx context.Context, items []DbSyncItem) error {
+	tx, err := conn.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, item := range items {
+		relation := postgresQuoteIdentifier(item.Schema) + "." + postgresQuoteIdentifier(item.Table)
+		comment := postgresQuoteLiteral(commentWithTrailingTags(item.Comment, item.Tags))
+
+		var query string
+		if item.Field != "" {
+			query = fmt.Sprintf(
+				"COMMENT ON COLUMN %s.%s IS %s",
+				relation, postgresQuoteIdentifier(item.Field), comment,
+			)
+		} else {
+			kind, err := conn.getPostgresRelationKind(ctx, tx, item.Schema, item.Table)
+			if err != nil {
+				return fmt.Errorf("cannot find %s: %s", item, err)
+			}
+			query = fmt.Sprintf("COMMENT ON %s %s IS %s", kind, relation, comment)
+		}
+
+		queryCtx, cancel := conn.QueryContext(ctx)
+		_, err := tx.ExecContext(queryCtx, query)
+		cancel()
+
+		if err != nil {
+			return fmt.Errorf("cannot update %s: %s", item, err)
+		}
+	}
+
+	return tx.Commit()
+}
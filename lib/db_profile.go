@@ -0,0 +1,274 @@
+// Copyright (C) 2021 Pau Sanchez
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// Fields are profiled from the first rows returned by the database for each
+// table (no ordering, so it is cheap even on big tables), reading only the
+// fields that have been selected. Fields tagged as PII, on themselves or on
+// their table, are never sampled.
+
+var profileExcludedTags = []string{"pii"}
+
+// -----------------------------------------------------------------------------
+// DbProfileOptions
+// -----------------------------------------------------------------------------
+type DbProfileOptions struct {
+	Include []string // patterns of the fields to profile: schema.table.field
+	Exclude []string // patterns of the fields that are never profiled
+	Rows    int      // maximum number of rows sampled on each table
+	Top     int      // maximum number of top values of each field
+}
+
+// -----------------------------------------------------------------------------
+// NewDbProfileOptions
+// -----------------------------------------------------------------------------
+func NewDbProfileOptions() DbProfileOptions {
+	return DbProfileOptions{
+		Include: []string{},
+		Exclude: []string{},
+		Rows:    10000,
+		Top:     5,
+	}
+}
+
+// -----------------------------------------------------------------------------
+// matchProfilePatterns
+//
+// Patterns (see path.Match) are matched against schema.table.field, or
+// table.field on databases without schemas. Patterns matching the table
+// match all its fields.
+// -----------------------------------------------------------------------------
+func matchProfilePatterns(patterns []string, schema string, table string, field string) bool {
+	tableName := table
+	if schema != NoDbSchemaLayoutName {
+		tableName = schema + "." + table
+	}
+
+	for _, pattern := range patterns {
+		for _, name := range []string{tableName, tableName + "." + field} {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// -----------------------------------------------------------------------------
+// hasProfileExcludedTag
+// -----------------------------------------------------------------------------
+func hasProfileExcludedTag(tags DbTags) bool {
+	return len(tags.Remove(profileExcludedTags...)) != len(tags)
+}
+
+// -----------------------------------------------------------------------------
+// mysqlQuoteIdentifier
+// -----------------------------------------------------------------------------
+func mysqlQuoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// -----------------------------------------------------------------------------
+// mssqlQuoteIdentifier
+// -----------------------------------------------------------------------------
+func mssqlQuoteIdentifier(name string) string {
+	return "[" + strings.ReplaceAll(name, "]", "]]") + "]"
+}
+
+// -----------------------------------------------------------------------------
+// sampleQueryParts
+//
+// Quoted list of columns and table (qualified with its schema, if any)
+// -----------------------------------------------------------------------------
+func sampleQueryParts(
+	quote func(string) string,
+	schema string,
+	table string,
+	columns []string,
+) (string, string) {
+	quoted := make([]string, 0, len(columns))
+	for _, column := range columns {
+		quoted = append(quoted, quote(column))
+	}
+
+	relation := quote(table)
+	if schema != NoDbSchemaLayoutName {
+		relation = quote(schema) + "." + relation
+	}
+
+	return strings.Join(quoted, ", "), relation
+}
+
+// -----------------------------------------------------------------------------
+// limitSampleQuery
+//
+// SampleQuery for databases that limit rows with LIMIT
+// -----------------------------------------------------------------------------
+func limitSampleQuery(quote func(string) string) func(string, string, []string, int) string {
+	return func(schema string, table string, columns []string, rows int) string {
+		columnList, relation := sampleQueryParts(quote, schema, table, columns)
+		return fmt.Sprintf("SELECT %s FROM %s LIMIT %d", columnList, relation, rows)
+	}
+}
+
+// -----------------------------------------------------------------------------
+// mssqlSampleQuery
+// -----------------------------------------------------------------------------
+func mssqlSampleQuery(schema string, table string, columns []string, rows int) string {
+	columnList, relation := sampleQueryParts(mssqlQuoteIdentifier, schema, table, columns)
+	return fmt.Sprintf("SELECT TOP %d %s FROM %s", rows, columnList, relation)
+}
+
+// -----------------------------------------------------------------------------
+// oracleSampleQuery
+// -----------------------------------------------------------------------------
+func oracleSampleQuery(schema string, table string, columns []string, rows int) string {
+	columnList, relation := sampleQueryParts(oracleQuoteIdentifier, schema, table, columns)
+	return fmt.Sprintf("SELECT %s FROM %s FETCH FIRST %d ROWS ONLY", columnList, relation, rows)
+}
+
+// -----------------------------------------------------------------------------
+// sampleValueString
+//
+// Text of a value as scanned from the database, or nil for NULL
+// -----------------------------------------------------------------------------
+func sampleValueString(value interface{}) *string {
+	var text string
+
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		text = string(v)
+	case time.Time:
+		text = v.Format("2006-01-02 15:04:05.999999999")
+	default:
+		text = fmt.Sprint(v)
+	}
+
+	return &text
+}
+
+// -----------------------------------------------------------------------------
+// sampleTable
+//
+// Values of given columns on the first rows of the table, column by column
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) sampleTable(
+	ctx context.Context,
+	schema string,
+	table string,
+	columns []string,
+	rows int,
+) ([][]*string, error) {
+	ctx, cancel := conn.QueryContext(ctx)
+	defer cancel()
+
+	query := conn.driver.SampleQuery(schema, table, columns, rows)
+	result, err := conn.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	values := make([][]*string, len(columns))
+	row := make([]interface{}, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range row {
+		dest[i] = &row[i]
+	}
+
+	for result.Next() {
+		if err := result.Scan(dest...); err != nil {
+			return nil, err
+		}
+		for i := range row {
+			values[i] = append(values[i], sampleValueString(row[i]))
+		}
+	}
+
+	return values, result.Err()
+}
+
+// -----------------------------------------------------------------------------
+// ProfileLayout
+//
+// Profile the values of the fields of given layout selected by the options,
+// on a sample of the rows of each table. Profiles of fields that cannot be
+// profiled (PII or excluded) are removed. Tables that cannot be sampled are
+// skipped and returned as warnings.
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) ProfileLayout(
+	ctx context.Context,
+	layout *DbLayout,
+	options DbProfileOptions,
+) ([]error, error) {
+	if conn.db == nil {
+		return nil, errors.New("Not connected to any database")
+	}
+
+	if conn.driver == nil || conn.driver.SampleQuery == nil {
+		return nil, errors.New("Don't know how to profile " + conn.driverType + " databases")
+	}
+
+	warnings := []error{}
+
+	for _, schemaLayout := range layout.Schemas {
+		for _, tableLayout := range schemaLayout.Tables {
+			if strings.HasPrefix(tableLayout.Name, DeletedPrefix) {
+				continue
+			}
+
+			fields := []*DbFieldLayout{}
+			for _, field := range tableLayout.Fields {
+				if hasProfileExcludedTag(tableLayout.Tags) ||
+					hasProfileExcludedTag(field.Tags) ||
+					matchProfilePatterns(options.Exclude, schemaLayout.Name, tableLayout.Name, field.Name) {
+					field.Profile = ""
+					continue
+				}
+
+				if !strings.HasPrefix(field.Name, DeletedPrefix) &&
+					matchProfilePatterns(options.Include, schemaLayout.Name, tableLayout.Name, field.Name) {
+					fields = append(fields, field)
+				}
+			}
+
+			if len(fields) == 0 {
+				continue
+			}
+
+			columns := make([]string, 0, len(fields))
+			for _, field := range fields {
+				columns = append(columns, field.Name)
+			}
+
+			conn.LogProgress("Profiling %d field(s) of '%s'", len(fields), DbTaggedItem{Schema: schemaLayout.Name, Table: tableLayout.Name})
+
+			values, err := conn.sampleTable(ctx, schemaLayout.Name, tableLayout.Name, columns, options.Rows)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, err
+				}
+				warnings = append(warnings, fmt.Errorf("cannot profile %s: %s", DbTaggedItem{Schema: schemaLayout.Name, Table: tableLayout.Name}, err))
+				continue
+			}
+
+			for i, field := range fields {
+				profile := NewDbFieldProfile(values[i], options.Top)
+				field.Profile = profile.String()
+			}
+		}
+	}
+
+	return warnings, nil
+}
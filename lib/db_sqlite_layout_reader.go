@@ -3,40 +3,61 @@ package lib
 
 import (
 	"context"
+	"regexp"
+	"strconv"
+	"strings"
+)
 
-	"github.com/georgysavva/scany/sqlscan"
+// CREATE TRIGGER name [BEFORE | AFTER | INSTEAD OF] event ON table ...
+var sqliteTriggerRe = regexp.MustCompile(
+	`(?is)^\s*CREATE\s+(?:TEMP\s+|TEMPORARY\s+)?TRIGGER\s+(?:IF\s+NOT\s+EXISTS\s+)?` +
+		"(?:\"[^\"]*\"|`[^`]*`|\\[[^\\]]*\\]|\\S+)\\s+" +
+		`(BEFORE\s+|AFTER\s+|INSTEAD\s+OF\s+)?(DELETE|INSERT|UPDATE)\b`,
 )
 
 // -----------------------------------------------------------------------------
 // getSqliteDbLayout
 // -----------------------------------------------------------------------------
-func (conn *DbConnection) getSqliteDbLayout() (*DbLayout, error) {
+func (conn *DbConnection) getSqliteDbLayout(ctx context.Context) (*DbLayout, error) {
 	dbLayout := NewDbLayout(conn.dbName)
 	dbLayout.Type = DbTypeSqlite
 
-	err := conn.fetchSqliteColumnInfo(&dbLayout)
+	err := conn.fetchSqliteColumnInfo(ctx, &dbLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.LogProgress("Reading triggers")
+	err = conn.fetchSqliteTriggers(ctx, &dbLayout)
 	if err != nil {
 		return nil, err
 	}
 
+	if conn.withStats {
+		conn.LogProgress("Reading table statistics")
+		err = conn.fetchSqliteTableStats(ctx, &dbLayout)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &dbLayout, nil
 }
 
 // -----------------------------------------------------------------------------
 // fetchSqliteColumnInfo
 // -----------------------------------------------------------------------------
-func (conn *DbConnection) fetchSqliteColumnInfo(dbLayout *DbLayout) error {
-	// read: https://www.sqlite.org/schematab.html for more info
+func (conn *DbConnection) fetchSqliteColumnInfo(ctx context.Context, dbLayout *DbLayout) error {
+	// read: https://www.sqlite.org/schematab.html for more info, internal
+	// tables (e.g. sqlite_sequence or sqlite_stat1) are skipped
 	tableNames := []string{}
 
-	ctx := context.Background()
-	err := sqlscan.Select(
+	err := conn.SelectContext(
 		ctx,
-		conn.db,
 		&tableNames,
 		`SELECT name AS table_name
 		   FROM sqlite_master
-		  WHERE type = 'table' and table_name != 'sqlite_sequence'
+		  WHERE type = 'table' and table_name NOT LIKE 'sqlite\_%' ESCAPE '\'
   	`,
 	)
 	if err != nil {
@@ -52,11 +73,11 @@ func (conn *DbConnection) fetchSqliteColumnInfo(dbLayout *DbLayout) error {
 	}
 
 	for _, tableName := range tableNames {
+		conn.LogProgress("Reading columns of table '%s'", tableName)
 		columns := []SqliteColumnDef{}
 
-		err := sqlscan.Select(
+		err := conn.SelectContext(
 			ctx,
-			conn.db,
 			&columns,
 			`SELECT name, type, [notnull] as not_null, COALESCE(dflt_value, '') as def_val, pk
 			   FROM pragma_table_info(?)`,
@@ -90,3 +111,144 @@ func (conn *DbConnection) fetchSqliteColumnInfo(dbLayout *DbLayout) error {
 
 	return nil
 }
+
+// -----------------------------------------------------------------------------
+// fetchSqliteTriggers
+//
+// SQLite only keeps the statement that created each trigger, so timing and
+// event are read from it. Triggers always run for each row, and run
+// statements of their own rather than a function.
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) fetchSqliteTriggers(ctx context.Context, dbLayout *DbLayout) error {
+	type SqliteTriggerDef struct {
+		Name      string `db:"name"`
+		TableName string `db:"tbl_name"`
+		Sql       string `db:"sql"`
+	}
+
+	triggerDefs := []SqliteTriggerDef{}
+
+	err := conn.SelectContext(
+		ctx,
+		&triggerDefs,
+		`SELECT name, tbl_name, COALESCE(sql, '') AS sql
+		   FROM sqlite_master
+		  WHERE type = 'trigger'
+		`,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, triggerDef := range triggerDefs {
+		table := dbLayout.GetTable(NoDbSchemaLayoutName, triggerDef.TableName)
+		if table == nil {
+			continue
+		}
+
+		m := sqliteTriggerRe.FindStringSubmatch(triggerDef.Sql)
+		if m == nil {
+			conn.LogProgress("Ignoring trigger '%s': cannot parse its definition", triggerDef.Name)
+			continue
+		}
+
+		trigger := NewDbTriggerLayout(triggerDef.Name)
+		trigger.Timing = strings.ToUpper(singleLine(m[1]))
+		if trigger.Timing == "" {
+			trigger.Timing = "BEFORE"
+		}
+		trigger.Events = []string{strings.ToUpper(m[2])}
+		trigger.Level = "ROW"
+
+		table.Triggers = append(table.Triggers, &trigger)
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// fetchSqliteTableStats
+//
+// Rows are only known on databases that have been analyzed (ANALYZE), as the
+// first number of the statistics of each table or index. Sizes are read from
+// dbstat, which is not available on the bundled SQLite (see README). Internal
+// tables are not documented, so their statistics are skipped.
+// -----------------------------------------------------------------------------
+func (conn *DbConnection) fetchSqliteTableStats(ctx context.Context, dbLayout *DbLayout) error {
+	type SqliteTableStat struct {
+		TableName string `db:"tbl"`
+		Stat      string `db:"stat"`
+	}
+
+	type SqliteTableSize struct {
+		TableName string `db:"tbl_name"`
+		Size      int64  `db:"size"`
+	}
+
+	analyzed := []int{}
+	err := conn.SelectContext(
+		ctx,
+		&analyzed,
+		`SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'sqlite_stat1'`,
+	)
+	if err != nil {
+		return err
+	}
+
+	tableStats := []SqliteTableStat{}
+	if len(analyzed) > 0 && analyzed[0] > 0 {
+		err = conn.SelectContext(ctx, &tableStats, `SELECT tbl, COALESCE(stat, '') AS stat FROM sqlite_stat1`)
+		if err != nil {
+			return err
+		}
+	}
+
+	tableSizes := []SqliteTableSize{}
+	err = conn.SelectContext(
+		ctx,
+		&tableSizes,
+		`SELECT m.tbl_name, SUM(d.pgsize) AS size
+		   FROM dbstat d
+		  INNER JOIN sqlite_master m ON (m.name = d.name)
+		  GROUP BY m.tbl_name
+		`,
+	)
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		conn.LogProgress("Table sizes are not available: %s", err)
+	}
+
+	getStats := func(tableName string) *DbTableStats {
+		table := dbLayout.LookupTable(NoDbSchemaLayoutName, tableName)
+		if table == nil {
+			return nil
+		}
+		if table.Stats == nil {
+			stats := NewDbTableStats()
+			table.Stats = &stats
+		}
+		return table.Stats
+	}
+
+	for _, tableStat := range tableStats {
+		fields := strings.Fields(tableStat.Stat)
+		if len(fields) == 0 {
+			continue
+		}
+
+		rows, err := strconv.ParseInt(fields[0], 10, 64)
+		if stats := getStats(tableStat.TableName); stats != nil && err == nil && rows > stats.Rows {
+			stats.Rows = rows
+		}
+	}
+
+	for _, tableSize := range tableSizes {
+		if stats := getStats(tableSize.TableName); stats != nil {
+			stats.Size = tableSize.Size
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,245 @@
+// Copyright (C) 2021 Pau Sanchez
+package lib
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Comments are stored as blocks separated by an empty line. Plain paragraphs
+// are kept in a single line so they can be wrapped to any length, whereas
+// lists and code blocks are kept verbatim, line by line.
+
+// list markers, including "-" escaped by escapeComment
+var commentListItemRe = regexp.MustCompile(`^(\\?-|[*+]|[0-9]+[.)])(\s|$)`)
+
+// leading "-", maybe already escaped, that TextEscape escapes
+var textLeadingDashRe = regexp.MustCompile(`^\\*-`)
+
+// -----------------------------------------------------------------------------
+// isCommentFence
+//
+// Returns the fence marker (``` or ~~~) if the line opens or closes a fenced
+// code block, or empty string otherwise.
+// -----------------------------------------------------------------------------
+func isCommentFence(line string) string {
+	trimmed := strings.TrimSpace(line)
+	for _, marker := range []string{"```", "~~~"} {
+		if strings.HasPrefix(trimmed, marker) {
+			return marker
+		}
+	}
+	return ""
+}
+
+// -----------------------------------------------------------------------------
+// isCommentListItem
+// -----------------------------------------------------------------------------
+func isCommentListItem(line string) bool {
+	return commentListItemRe.MatchString(strings.TrimSpace(line))
+}
+
+// -----------------------------------------------------------------------------
+// lineIndent
+//
+// Number of leading spaces (tabs count as 4) of the given line
+// -----------------------------------------------------------------------------
+func lineIndent(line string) int {
+	indent := 0
+	for _, c := range line {
+		switch c {
+		case ' ':
+			indent++
+		case '\t':
+			indent += 4
+		default:
+			return indent
+		}
+	}
+	return indent
+}
+
+// -----------------------------------------------------------------------------
+// splitCommentBlocks
+//
+// Split comment lines in blocks separated by empty lines. Fenced code blocks
+// are never split, even if they contain empty lines.
+// -----------------------------------------------------------------------------
+func splitCommentBlocks(lines []string) [][]string {
+	blocks := [][]string{}
+	block := []string{}
+	fence := ""
+
+	for _, line := range lines {
+		if fence == "" && strings.TrimSpace(line) == "" {
+			if len(block) > 0 {
+				blocks = append(blocks, block)
+				block = []string{}
+			}
+			continue
+		}
+
+		if marker := isCommentFence(line); marker != "" {
+			if fence == "" {
+				fence = marker
+			} else if marker == fence {
+				fence = ""
+			}
+		}
+
+		block = append(block, line)
+	}
+
+	if len(block) > 0 {
+		blocks = append(blocks, block)
+	}
+
+	return blocks
+}
+
+// -----------------------------------------------------------------------------
+// isVerbatimCommentBlock
+//
+// Lists, fenced code blocks and indented code blocks should be printed as they
+// are, without wrapping them.
+// -----------------------------------------------------------------------------
+func isVerbatimCommentBlock(block []string) bool {
+	allIndented := true
+	for _, line := range block {
+		if isCommentFence(line) != "" || isCommentListItem(line) {
+			return true
+		}
+		if lineIndent(line) < 4 {
+			allIndented = false
+		}
+	}
+	return allIndented
+}
+
+// -----------------------------------------------------------------------------
+// mapVerbatimBlock
+//
+// Apply given function to the text of each line of a verbatim block, leaving
+// code untouched and list markers as they are. Escaped markers (\-) go through
+// the function too.
+// -----------------------------------------------------------------------------
+func mapVerbatimBlock(block []string, fn EscapeFunc) []string {
+	fence := ""
+	result := make([]string, 0, len(block))
+
+	for _, line := range block {
+		if marker := isCommentFence(line); marker != "" && (fence == "" || marker == fence) {
+			if fence == "" {
+				fence = marker
+			} else {
+				fence = ""
+			}
+			result = append(result, line)
+			continue
+		}
+
+		switch {
+		case fence != "" || lineIndent(line) >= 4:
+			result = append(result, line)
+		case isCommentListItem(line):
+			indent := line[:len(line)-len(strings.TrimLeft(line, " "))]
+			marker := commentListItemRe.FindString(strings.TrimSpace(line))
+			text := strings.TrimSpace(line)[len(marker):]
+			if strings.HasPrefix(marker, "\\") {
+				marker = fn(strings.TrimSpace(marker)) + marker[len(strings.TrimSpace(marker)):]
+			}
+			result = append(result, indent+marker+fn(text))
+		default:
+			result = append(result, fn(line))
+		}
+	}
+
+	return result
+}
+
+// -----------------------------------------------------------------------------
+// NormalizeComment
+//
+// Converts the raw comment lines read from a file into a comment: common
+// indentation is removed, paragraphs are joined in a single line and lists
+// and code blocks are preserved. Text outside code is unescaped.
+// -----------------------------------------------------------------------------
+func NormalizeComment(lines []string, unescape EscapeFunc) string {
+	minIndent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if indent := lineIndent(line); minIndent < 0 || indent < minIndent {
+			minIndent = indent
+		}
+	}
+
+	dedented := make([]string, 0, len(lines))
+	for _, line := range lines {
+		line = strings.TrimRight(strings.ReplaceAll(line, "\t", "    "), " ")
+		if len(line) >= minIndent && minIndent > 0 {
+			line = line[minIndent:]
+		}
+		dedented = append(dedented, line)
+	}
+
+	blocks := []string{}
+	for _, block := range splitCommentBlocks(dedented) {
+		if isVerbatimCommentBlock(block) {
+			blocks = append(blocks, strings.Join(mapVerbatimBlock(block, unescape), "\n"))
+		} else {
+			paragraph := strings.Join(strings.Fields(strings.Join(block, " ")), " ")
+			blocks = append(blocks, unescape(paragraph))
+		}
+	}
+
+	return strings.Join(blocks, "\n\n")
+}
+
+// -----------------------------------------------------------------------------
+// isAmbiguousListItem
+//
+// Whether a line of a comment would be read back as a field or object: a list
+// starting the comment (there is no text before it yet), or list items that
+// look like a field or object anywhere.
+// -----------------------------------------------------------------------------
+func isAmbiguousListItem(line string, isFirst bool) bool {
+	trimmed := strings.TrimSpace(line)
+	if lineIndent(line) >= 4 || !isCommentListItem(trimmed) || trimmed[0] != '-' {
+		return false
+	}
+	return isFirst || fieldLineRe.MatchString(trimmed) || objectLineRe.MatchString(trimmed)
+}
+
+// -----------------------------------------------------------------------------
+// escapeComment
+//
+// Escape the text of a comment, leaving code blocks untouched and list markers
+// unescaped, so they are still rendered as lists. Only the markers of list
+// items that would be read as a field are escaped (see isAmbiguousListItem).
+// -----------------------------------------------------------------------------
+func escapeComment(comment string, escape EscapeFunc) string {
+	blocks := []string{}
+	for i, block := range splitCommentBlocks(strings.Split(comment, "\n")) {
+		if isVerbatimCommentBlock(block) {
+			lines := mapVerbatimBlock(block, escape)
+			fence := ""
+			for j, line := range block {
+				if marker := isCommentFence(line); marker != "" && fence == "" {
+					fence = marker
+				} else if marker != "" && marker == fence {
+					fence = ""
+				} else if fence == "" && isAmbiguousListItem(line, i == 0 && j == 0) {
+					indent := lineIndent(lines[j])
+					lines[j] = lines[j][:indent] + "\\" + lines[j][indent:]
+				}
+			}
+			blocks = append(blocks, strings.Join(lines, "\n"))
+		} else {
+			blocks = append(blocks, escape(strings.Join(block, "\n")))
+		}
+	}
+
+	return strings.Join(blocks, "\n\n")
+}
@@ -0,0 +1,183 @@
+// Copyright (C) 2021 Pau Sanchez
+package lib
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+// csvBool
+// -----------------------------------------------------------------------------
+func csvBool(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "yes", "y", "true", "1", "x":
+		return true
+	}
+	return false
+}
+
+// -----------------------------------------------------------------------------
+// csvComment
+//
+// Spreadsheets might save line breaks as \r\n
+// -----------------------------------------------------------------------------
+func csvComment(value string) string {
+	value = strings.ReplaceAll(value, "\r\n", "\n")
+	return NormalizeComment(strings.Split(value, "\n"), IdentityEscape)
+}
+
+// -----------------------------------------------------------------------------
+// csvUnquoteFormula
+//
+// Undo csvQuoteFormula, so cells that look like a formula are read as written.
+// Only the quote added by csvQuoteFormula is removed, so 'NONE' is kept.
+// -----------------------------------------------------------------------------
+func csvUnquoteFormula(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune(csvQuotedChars, rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
+
+// -----------------------------------------------------------------------------
+// NewDbLayoutFromParsedCsv
+//
+// Read a data dictionary written by PrintCsv. Columns are found by the name on
+// the header row, so they can be reordered or removed, but at least table and
+// column should be there.
+//
+// Table comments are repeated on every row of the table: the first row of the
+// table always wins, and any row with a different comment gets a diagnostic.
+// Diagnostics have the row number as line.
+// -----------------------------------------------------------------------------
+func NewDbLayoutFromParsedCsv(text string, separator rune) (*DbLayout, []DbLayoutDiagnostic, error) {
+	layout := NewDbLayout("")
+	diagnostics := []DbLayoutDiagnostic{}
+
+	// some spreadsheets add a byte order mark at the beginning
+	text = strings.TrimPrefix(text, "\ufeff")
+
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.Comma = separator
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return &layout, diagnostics, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	if _, ok := columns["table"]; !ok {
+		return nil, nil, errors.New("missing 'table' column on the header")
+	}
+	if _, ok := columns["column"]; !ok {
+		return nil, nil, errors.New("missing 'column' column on the header")
+	}
+
+	// tables whose comment has been taken from their first row
+	seenTables := map[*DbTableLayout]bool{}
+
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, err
+		}
+
+		value := func(column string) (string, bool) {
+			i, ok := columns[column]
+			if !ok || i >= len(record) {
+				return "", false
+			}
+			return csvUnquoteFormula(record[i]), true
+		}
+
+		tableName, _ := value("table")
+		tableName = strings.TrimSpace(tableName)
+		if tableName == "" {
+			diagnostics = append(diagnostics, DbLayoutDiagnostic{
+				Line:    row,
+				Column:  1,
+				Message: "ignoring row without a table",
+			})
+			continue
+		}
+
+		if databaseName, ok := value("database"); ok && layout.Name == "" {
+			layout.Name = strings.TrimSpace(databaseName)
+		}
+
+		schemaName, _ := value("schema")
+		schemaLayout := layout.GetOrCreateSchema(strings.TrimSpace(schemaName))
+		tableLayout := schemaLayout.GetOrCreateTable(tableName)
+
+		if tableComment, ok := value("table comment"); ok {
+			tableComment = csvComment(tableComment)
+			if !seenTables[tableLayout] {
+				seenTables[tableLayout] = true
+				tableLayout.Comment = tableComment
+			} else if tableComment != tableLayout.Comment {
+				diagnostics = append(diagnostics, DbLayoutDiagnostic{
+					Line:    row,
+					Column:  columns["table comment"] + 1,
+					Message: fmt.Sprintf("ignoring comment for table '%s' that differs from its first row", tableName),
+				})
+			}
+		}
+
+		if tableTags, ok := value("table tags"); ok {
+			_, tags := ParseTags(tableTags)
+			tableLayout.Tags = tableLayout.Tags.Add(tags...)
+		}
+
+		fieldName, _ := value("column")
+		fieldName = strings.TrimSpace(fieldName)
+		if fieldName == "" {
+			continue
+		}
+
+		field := NewDbFieldLayout(fieldName)
+		if typeString, ok := value("type"); ok {
+			field.Type = strings.TrimSpace(typeString)
+		}
+		if nullable, ok := value("nullable"); ok {
+			field.IsNullable = csvBool(nullable)
+		}
+		if pk, ok := value("pk"); ok {
+			field.IsPrimaryKey = csvBool(pk)
+		}
+		if defaultValue, ok := value("default"); ok {
+			field.Default = defaultValue
+		}
+		if comment, ok := value("column comment"); ok {
+			field.Comment = csvComment(comment)
+		}
+		if fieldTags, ok := value("column tags"); ok {
+			_, field.Tags = ParseTags(fieldTags)
+		}
+
+		if err := tableLayout.AddField(field); err != nil {
+			diagnostics = append(diagnostics, DbLayoutDiagnostic{
+				Line:    row,
+				Column:  columns["column"] + 1,
+				Message: "ignoring row: " + err.Error(),
+			})
+		}
+	}
+
+	layout.ExtractTags()
+	layout.RebuildLookups()
+
+	return &layout, diagnostics, nil
+}
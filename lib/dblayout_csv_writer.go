@@ -0,0 +1,115 @@
+// Copyright (C) 2021 Pau Sanchez
+package lib
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+// Spreadsheets evaluate cells starting with any of these as a formula
+const csvFormulaChars = "=+-@"
+
+// Cells starting with any of these are prefixed with a single quote: formulas
+// and the quote itself, so cells are always read back as they were
+const csvQuotedChars = csvFormulaChars + "'"
+
+// Columns of the data dictionary, in the order they are written
+var CsvColumns = []string{
+	"database",
+	"schema",
+	"table",
+	"column",
+	"type",
+	"nullable",
+	"pk",
+	"default",
+	"table comment",
+	"column comment",
+	"table tags",
+	"column tags",
+}
+
+// -----------------------------------------------------------------------------
+// csvQuoteFormula
+//
+// Prefix cells that would be evaluated as a formula with a single quote, so
+// comments or defaults coming from the database never run on a spreadsheet.
+// Cells already starting with a quote (e.g. 'NONE') are prefixed too, so
+// csvUnquoteFormula knows which quotes to remove.
+// -----------------------------------------------------------------------------
+func csvQuoteFormula(value string) string {
+	if value != "" && strings.ContainsRune(csvQuotedChars, rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// -----------------------------------------------------------------------------
+// writeCsvRecord
+// -----------------------------------------------------------------------------
+func writeCsvRecord(writer *csv.Writer, record []string) error {
+	for i := range record {
+		record[i] = csvQuoteFormula(record[i])
+	}
+	return writer.Write(record)
+}
+
+// -----------------------------------------------------------------------------
+// PrintCsv
+//
+// Print a data dictionary with one row per field, that can be opened with any
+// spreadsheet. Use ',' as separator for CSV or '\t' for TSV. Tables without
+// fields get a row with an empty column, so their comment is not lost.
+// -----------------------------------------------------------------------------
+func (dbLayout *DbLayout) PrintCsv(out io.Writer, separator rune) error {
+	writer := csv.NewWriter(out)
+	writer.Comma = separator
+
+	if err := writer.Write(CsvColumns); err != nil {
+		return err
+	}
+
+	for _, schemaLayout := range dbLayout.Schemas {
+		for _, tableLayout := range schemaLayout.Tables {
+			if len(tableLayout.Fields) == 0 {
+				err := writeCsvRecord(writer, []string{
+					dbLayout.Name,
+					schemaLayout.Name,
+					tableLayout.Name,
+					"", "", "", "", "",
+					tableLayout.Comment,
+					"",
+					tableLayout.Tags.String(),
+					"",
+				})
+				if err != nil {
+					return err
+				}
+			}
+
+			for _, field := range tableLayout.Fields {
+				err := writeCsvRecord(writer, []string{
+					dbLayout.Name,
+					schemaLayout.Name,
+					tableLayout.Name,
+					field.Name,
+					typeString(field),
+					yesNo(field.IsNullable),
+					yesNo(field.IsPrimaryKey),
+					field.Default,
+					tableLayout.Comment,
+					field.Comment,
+					tableLayout.Tags.String(),
+					field.Tags.String(),
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,984 @@
+// Copyright (C) 2021 Pau Sanchez
+package lib
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+const (
+	DBML_TOKEN_WORD   = 0 // identifiers, numbers, colors, ...
+	DBML_TOKEN_STRING = 1 // 'string' or '''multi-line string'''
+	DBML_TOKEN_QUOTED = 2 // "quoted identifier"
+	DBML_TOKEN_EXPR   = 3 // `expression`
+	DBML_TOKEN_PUNCT  = 4 // { } [ ] ( ) : , . > < - <>
+)
+
+// -----------------------------------------------------------------------------
+// dbmlToken
+//
+// Start and End are byte offsets on the original source, so raw values (like
+// defaults) can be extracted as they were written.
+// -----------------------------------------------------------------------------
+type dbmlToken struct {
+	Kind  int
+	Text  string
+	Start int
+	End   int
+	Line  int
+}
+
+// -----------------------------------------------------------------------------
+// DbLayoutDbmlParser
+//
+// Simple recursive descent parser for DBML files. Only the parts of DBML that
+// can be represented in a DbLayout are kept, the rest (table groups, indexes,
+// sticky notes...) are parsed but ignored.
+//
+// More info:
+//   - https://www.dbml.org/docs/
+// -----------------------------------------------------------------------------
+type DbLayoutDbmlParser struct {
+	LayoutPtr *DbLayout
+
+	source string
+	tokens []dbmlToken
+	pos    int
+}
+
+// -----------------------------------------------------------------------------
+// NewDbLayoutDbmlParser
+// -----------------------------------------------------------------------------
+func NewDbLayoutDbmlParser(layoutPtr *DbLayout) DbLayoutDbmlParser {
+	return DbLayoutDbmlParser{
+		LayoutPtr: layoutPtr,
+		source:    "",
+		tokens:    []dbmlToken{},
+		pos:       0,
+	}
+}
+
+// -----------------------------------------------------------------------------
+// tokenizeDbml
+// -----------------------------------------------------------------------------
+func tokenizeDbml(source string) ([]dbmlToken, error) {
+	tokens := []dbmlToken{}
+	line := 1
+
+	isPunct := func(c byte) bool {
+		return strings.IndexByte("{}[]():,.<>-", c) >= 0
+	}
+
+	isWordChar := func(c byte) bool {
+		return !isPunct(c) &&
+			!unicode.IsSpace(rune(c)) &&
+			c != '\'' && c != '"' && c != '`' && c != '/'
+	}
+
+	i := 0
+	for i < len(source) {
+		c := source[i]
+
+		switch {
+		case c == '\n':
+			line++
+			i++
+
+		case unicode.IsSpace(rune(c)):
+			i++
+
+		// single line comment
+		case strings.HasPrefix(source[i:], "//"):
+			for i < len(source) && source[i] != '\n' {
+				i++
+			}
+
+		// multi-line comment
+		case strings.HasPrefix(source[i:], "/*"):
+			end := strings.Index(source[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated comment", line)
+			}
+			line += strings.Count(source[i:i+2+end+2], "\n")
+			i += 2 + end + 2
+
+		// multi-line string
+		case strings.HasPrefix(source[i:], "'''"):
+			buff := strings.Builder{}
+			start := i
+			i += 3
+			for i < len(source) && !strings.HasPrefix(source[i:], "'''") {
+				if source[i] == '\\' && i+1 < len(source) {
+					i++
+				}
+				buff.WriteByte(source[i])
+				i++
+			}
+			if i >= len(source) {
+				return nil, fmt.Errorf("line %d: unterminated multi-line string", line)
+			}
+			i += 3
+			tokens = append(tokens, dbmlToken{DBML_TOKEN_STRING, unindentDbmlString(buff.String()), start, i, line})
+			line += strings.Count(source[start:i], "\n")
+
+		case c == '\'' || c == '"' || c == '`':
+			kind := DBML_TOKEN_STRING
+			if c == '"' {
+				kind = DBML_TOKEN_QUOTED
+			} else if c == '`' {
+				kind = DBML_TOKEN_EXPR
+			}
+
+			buff := strings.Builder{}
+			start := i
+			i++
+			for i < len(source) && source[i] != c {
+				if source[i] == '\\' && i+1 < len(source) {
+					i++
+				}
+				if source[i] == '\n' {
+					line++
+				}
+				buff.WriteByte(source[i])
+				i++
+			}
+			if i >= len(source) {
+				return nil, fmt.Errorf("line %d: unterminated string", line)
+			}
+			i++
+			tokens = append(tokens, dbmlToken{kind, buff.String(), start, i, line})
+
+		case c == '<' && i+1 < len(source) && source[i+1] == '>':
+			tokens = append(tokens, dbmlToken{DBML_TOKEN_PUNCT, "<>", i, i + 2, line})
+			i += 2
+
+		case isPunct(c):
+			tokens = append(tokens, dbmlToken{DBML_TOKEN_PUNCT, string(c), i, i + 1, line})
+			i++
+
+		default:
+			start := i
+			for i < len(source) && isWordChar(source[i]) {
+				i++
+			}
+			if start == i {
+				return nil, fmt.Errorf("line %d: unexpected character '%c'", line, c)
+			}
+			tokens = append(tokens, dbmlToken{DBML_TOKEN_WORD, source[start:i], start, i, line})
+		}
+	}
+
+	return tokens, nil
+}
+
+// -----------------------------------------------------------------------------
+// unindentDbmlString
+//
+// Multi-line strings have the common indentation of all lines removed, as well
+// as the leading and trailing empty lines.
+// -----------------------------------------------------------------------------
+func unindentDbmlString(raw string) string {
+	lines := strings.Split(raw, "\n")
+
+	minIndent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		if minIndent < 0 || indent < minIndent {
+			minIndent = indent
+		}
+	}
+
+	for i, line := range lines {
+		if len(line) >= minIndent && minIndent > 0 {
+			lines[i] = line[minIndent:]
+		}
+		lines[i] = strings.TrimRight(lines[i], " \t")
+	}
+
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+// -----------------------------------------------------------------------------
+// Helpers to walk the token list
+// -----------------------------------------------------------------------------
+func (parser *DbLayoutDbmlParser) eof() bool {
+	return parser.pos >= len(parser.tokens)
+}
+
+func (parser *DbLayoutDbmlParser) peek() *dbmlToken {
+	if parser.eof() {
+		return nil
+	}
+	return &parser.tokens[parser.pos]
+}
+
+func (parser *DbLayoutDbmlParser) peekIs(text string) bool {
+	token := parser.peek()
+	return token != nil && token.Kind == DBML_TOKEN_PUNCT && token.Text == text
+}
+
+func (parser *DbLayoutDbmlParser) peekKeyword(keyword string) bool {
+	token := parser.peek()
+	return token != nil && token.Kind == DBML_TOKEN_WORD && strings.EqualFold(token.Text, keyword)
+}
+
+func (parser *DbLayoutDbmlParser) next() (*dbmlToken, error) {
+	if parser.eof() {
+		return nil, parser.errorf("unexpected end of file")
+	}
+	token := &parser.tokens[parser.pos]
+	parser.pos++
+	return token, nil
+}
+
+func (parser *DbLayoutDbmlParser) expect(text string) error {
+	token, err := parser.next()
+	if err != nil {
+		return err
+	}
+	if token.Kind != DBML_TOKEN_PUNCT || token.Text != text {
+		parser.pos--
+		return parser.errorf("expected '%s' but found '%s'", text, token.Text)
+	}
+	return nil
+}
+
+func (parser *DbLayoutDbmlParser) errorf(format string, args ...interface{}) error {
+	line := 0
+	if len(parser.tokens) > 0 {
+		if parser.eof() {
+			line = parser.tokens[len(parser.tokens)-1].Line
+		} else {
+			line = parser.tokens[parser.pos].Line
+		}
+	}
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+// -----------------------------------------------------------------------------
+// parseName
+//
+// Reads an identifier, either plain or double quoted
+// -----------------------------------------------------------------------------
+func (parser *DbLayoutDbmlParser) parseName() (string, error) {
+	token, err := parser.next()
+	if err != nil {
+		return "", err
+	}
+	if token.Kind != DBML_TOKEN_WORD && token.Kind != DBML_TOKEN_QUOTED {
+		parser.pos--
+		return "", parser.errorf("expected a name but found '%s'", token.Text)
+	}
+	return token.Text, nil
+}
+
+// -----------------------------------------------------------------------------
+// parseQualifiedName
+//
+// Reads schema.name or name, in which case NoDbSchemaLayoutName is returned as
+// the schema.
+// -----------------------------------------------------------------------------
+func (parser *DbLayoutDbmlParser) parseQualifiedName() (string, string, error) {
+	name, err := parser.parseName()
+	if err != nil {
+		return "", "", err
+	}
+
+	if !parser.peekIs(".") {
+		return NoDbSchemaLayoutName, name, nil
+	}
+
+	parser.pos++
+	table, err := parser.parseName()
+	if err != nil {
+		return "", "", err
+	}
+
+	return name, table, nil
+}
+
+// -----------------------------------------------------------------------------
+// skipBlock
+//
+// Skips a { ... } block including all nested blocks
+// -----------------------------------------------------------------------------
+func (parser *DbLayoutDbmlParser) skipBlock() error {
+	if err := parser.expect("{"); err != nil {
+		return err
+	}
+
+	depth := 1
+	for depth > 0 {
+		token, err := parser.next()
+		if err != nil {
+			return err
+		}
+		if token.Kind != DBML_TOKEN_PUNCT {
+			continue
+		}
+		if token.Text == "{" {
+			depth++
+		} else if token.Text == "}" {
+			depth--
+		}
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// parseNote
+//
+// Parses both forms of notes, once the Note keyword has been consumed:
+//   Note: 'text'
+//   Note { 'text' }
+// -----------------------------------------------------------------------------
+func (parser *DbLayoutDbmlParser) parseNote() (string, error) {
+	isBlock := parser.peekIs("{")
+	if isBlock {
+		parser.pos++
+	} else if err := parser.expect(":"); err != nil {
+		return "", err
+	}
+
+	token, err := parser.next()
+	if err != nil {
+		return "", err
+	}
+	if token.Kind != DBML_TOKEN_STRING {
+		parser.pos--
+		return "", parser.errorf("expected a string for the note but found '%s'", token.Text)
+	}
+
+	if isBlock {
+		if err := parser.expect("}"); err != nil {
+			return "", err
+		}
+	}
+
+	return NormalizeComment(strings.Split(token.Text, "\n"), IdentityEscape), nil
+}
+
+// -----------------------------------------------------------------------------
+// dbmlSetting
+// -----------------------------------------------------------------------------
+type dbmlSetting struct {
+	Key    string      // lowercase key, e.g. "not null", "default", "note"
+	Value  string      // raw value as it was written
+	Tokens []dbmlToken // value tokens
+}
+
+// -----------------------------------------------------------------------------
+// parseSettings
+//
+// Parses [key: value, flag, other flag, ...]
+// -----------------------------------------------------------------------------
+func (parser *DbLayoutDbmlParser) parseSettings() ([]dbmlSetting, error) {
+	settings := []dbmlSetting{}
+
+	if err := parser.expect("["); err != nil {
+		return nil, err
+	}
+
+	for !parser.peekIs("]") {
+		keyWords := []string{}
+		for !parser.eof() && !parser.peekIs(":") && !parser.peekIs(",") && !parser.peekIs("]") {
+			token, _ := parser.next()
+			keyWords = append(keyWords, strings.ToLower(token.Text))
+		}
+
+		setting := dbmlSetting{Key: strings.Join(keyWords, " ")}
+
+		if parser.peekIs(":") {
+			parser.pos++
+			depth := 0
+			for !parser.eof() {
+				if depth == 0 && (parser.peekIs(",") || parser.peekIs("]")) {
+					break
+				}
+				token, _ := parser.next()
+				if token.Kind == DBML_TOKEN_PUNCT && token.Text == "(" {
+					depth++
+				} else if token.Kind == DBML_TOKEN_PUNCT && token.Text == ")" {
+					depth--
+				}
+				setting.Tokens = append(setting.Tokens, *token)
+			}
+
+			if len(setting.Tokens) == 0 {
+				return nil, parser.errorf("missing value for setting '%s'", setting.Key)
+			}
+
+			first := setting.Tokens[0]
+			last := setting.Tokens[len(setting.Tokens)-1]
+			setting.Value = parser.source[first.Start:last.End]
+		}
+
+		if setting.Key == "" {
+			return nil, parser.errorf("empty setting")
+		}
+
+		settings = append(settings, setting)
+
+		if parser.peekIs(",") {
+			parser.pos++
+		} else if !parser.peekIs("]") {
+			return nil, parser.errorf("expected ',' or ']' on settings list")
+		}
+	}
+
+	return settings, parser.expect("]")
+}
+
+// -----------------------------------------------------------------------------
+// settingString
+//
+// Returns the unquoted value of a setting whose value is a single string
+// -----------------------------------------------------------------------------
+func settingString(setting dbmlSetting) string {
+	if len(setting.Tokens) == 1 && setting.Tokens[0].Kind == DBML_TOKEN_STRING {
+		return setting.Tokens[0].Text
+	}
+	return setting.Value
+}
+
+// -----------------------------------------------------------------------------
+// settingNote
+// -----------------------------------------------------------------------------
+func settingNote(setting dbmlSetting) string {
+	return NormalizeComment(strings.Split(settingString(setting), "\n"), IdentityEscape)
+}
+
+// -----------------------------------------------------------------------------
+// settingDefault
+//
+// Converts a DBML default value into its SQL-like representation:
+//   'text'    -> 'text'
+//   `now()`   -> now()
+//   123, true -> 123, true
+// -----------------------------------------------------------------------------
+func settingDefault(setting dbmlSetting) string {
+	if len(setting.Tokens) == 1 {
+		token := setting.Tokens[0]
+		switch token.Kind {
+		case DBML_TOKEN_STRING:
+			return "'" + strings.ReplaceAll(token.Text, "'", "''") + "'"
+		case DBML_TOKEN_EXPR:
+			return token.Text
+		}
+	}
+	return setting.Value
+}
+
+// -----------------------------------------------------------------------------
+// parseRefEndpoint
+//
+// Parses one side of a relationship from the given tokens:
+//   schema.table.field | table.field | table.(field1, field2)
+// -----------------------------------------------------------------------------
+func (parser *DbLayoutDbmlParser) parseRefEndpoint() (string, string, []string, error) {
+	parts := []string{}
+	fields := []string{}
+
+	for {
+		if parser.peekIs("(") {
+			parser.pos++
+			for !parser.peekIs(")") {
+				name, err := parser.parseName()
+				if err != nil {
+					return "", "", nil, err
+				}
+				fields = append(fields, name)
+				if parser.peekIs(",") {
+					parser.pos++
+				}
+			}
+			parser.pos++
+			break
+		}
+
+		name, err := parser.parseName()
+		if err != nil {
+			return "", "", nil, err
+		}
+		parts = append(parts, name)
+
+		if !parser.peekIs(".") {
+			break
+		}
+		parser.pos++
+	}
+
+	if len(fields) == 0 && len(parts) > 0 {
+		fields = append(fields, parts[len(parts)-1])
+		parts = parts[:len(parts)-1]
+	}
+
+	switch len(parts) {
+	case 1:
+		return NoDbSchemaLayoutName, parts[0], fields, nil
+	case 2:
+		return parts[0], parts[1], fields, nil
+	default:
+		return "", "", nil, parser.errorf("invalid relationship endpoint")
+	}
+}
+
+// -----------------------------------------------------------------------------
+// parseRelation
+// -----------------------------------------------------------------------------
+func (parser *DbLayoutDbmlParser) parseRelation() (string, error) {
+	token, err := parser.next()
+	if err != nil {
+		return "", err
+	}
+
+	switch token.Text {
+	case ">", "<", "-", "<>":
+		if token.Kind == DBML_TOKEN_PUNCT {
+			return token.Text, nil
+		}
+	}
+
+	parser.pos--
+	return "", parser.errorf("invalid relationship type '%s'", token.Text)
+}
+
+// -----------------------------------------------------------------------------
+// parseRefDefinition
+//
+//  from.field > to.field [settings]
+// -----------------------------------------------------------------------------
+func (parser *DbLayoutDbmlParser) parseRefDefinition(name string) error {
+	var err error
+	ref := DbRefLayout{Name: name}
+
+	ref.FromSchema, ref.FromTable, ref.FromFields, err = parser.parseRefEndpoint()
+	if err != nil {
+		return err
+	}
+
+	ref.Relation, err = parser.parseRelation()
+	if err != nil {
+		return err
+	}
+
+	ref.ToSchema, ref.ToTable, ref.ToFields, err = parser.parseRefEndpoint()
+	if err != nil {
+		return err
+	}
+
+	if parser.peekIs("[") {
+		start := parser.peek().Start
+		if _, err := parser.parseSettings(); err != nil {
+			return err
+		}
+		end := parser.tokens[parser.pos-1].Start
+		ref.Settings = strings.TrimSpace(parser.source[start+1 : end])
+	}
+
+	parser.LayoutPtr.Refs = append(parser.LayoutPtr.Refs, &ref)
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// ParseProject
+//
+//  Project name { database_type: 'PostgreSQL'  Note: '...' }
+// -----------------------------------------------------------------------------
+func (parser *DbLayoutDbmlParser) ParseProject() error {
+	if !parser.peekIs("{") {
+		name, err := parser.parseName()
+		if err != nil {
+			return err
+		}
+		parser.LayoutPtr.Name = name
+	}
+
+	if err := parser.expect("{"); err != nil {
+		return err
+	}
+
+	for !parser.peekIs("}") {
+		key, err := parser.parseName()
+		if err != nil {
+			return err
+		}
+
+		if strings.EqualFold(key, "note") {
+			parser.LayoutPtr.Comment, err = parser.parseNote()
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := parser.expect(":"); err != nil {
+			return err
+		}
+
+		value, err := parser.next()
+		if err != nil {
+			return err
+		}
+
+		if strings.EqualFold(key, "database_type") {
+			parser.LayoutPtr.Type = value.Text
+		}
+	}
+
+	return parser.expect("}")
+}
+
+// -----------------------------------------------------------------------------
+// ParseTable
+//
+//  Table schema.name as alias [settings] { fields, notes & indexes }
+// -----------------------------------------------------------------------------
+func (parser *DbLayoutDbmlParser) ParseTable() error {
+	schemaName, tableName, err := parser.parseQualifiedName()
+	if err != nil {
+		return err
+	}
+
+	schema := parser.LayoutPtr.GetOrCreateSchema(schemaName)
+	if _, ok := schema.TableLookup[tableName]; ok {
+		return parser.errorf("duplicate table '%s'", tableName)
+	}
+	table := schema.GetOrCreateTable(tableName)
+
+	if parser.peekKeyword("as") {
+		parser.pos++
+		if _, err := parser.parseName(); err != nil {
+			return err
+		}
+	}
+
+	if parser.peekIs("[") {
+		settings, err := parser.parseSettings()
+		if err != nil {
+			return err
+		}
+		for _, setting := range settings {
+			if setting.Key == "note" {
+				table.Comment = settingNote(setting)
+			}
+		}
+	}
+
+	if err := parser.expect("{"); err != nil {
+		return err
+	}
+
+	for !parser.peekIs("}") {
+		if parser.eof() {
+			return parser.errorf("unterminated table '%s'", tableName)
+		}
+
+		token := parser.peek()
+		isKeyword := token.Kind == DBML_TOKEN_WORD && parser.pos+1 < len(parser.tokens)
+		nextToken := dbmlToken{}
+		if isKeyword {
+			nextToken = parser.tokens[parser.pos+1]
+		}
+		nextIsBlock := nextToken.Kind == DBML_TOKEN_PUNCT && nextToken.Text == "{"
+		nextIsColon := nextToken.Kind == DBML_TOKEN_PUNCT && nextToken.Text == ":"
+
+		switch {
+		case isKeyword && strings.EqualFold(token.Text, "note") && (nextIsBlock || nextIsColon):
+			parser.pos++
+			table.Comment, err = parser.parseNote()
+			if err != nil {
+				return err
+			}
+
+		case isKeyword && strings.EqualFold(token.Text, "indexes") && nextIsBlock:
+			parser.pos++
+			if err := parser.skipBlock(); err != nil {
+				return err
+			}
+
+		default:
+			if err := parser.ParseField(schemaName, table); err != nil {
+				return err
+			}
+		}
+	}
+
+	return parser.expect("}")
+}
+
+// -----------------------------------------------------------------------------
+// ParseField
+//
+//  name type [settings]
+// -----------------------------------------------------------------------------
+func (parser *DbLayoutDbmlParser) ParseField(schemaName string, table *DbTableLayout) error {
+	name, err := parser.parseName()
+	if err != nil {
+		return err
+	}
+
+	field := NewDbFieldLayout(name)
+	field.IsNullable = true
+
+	// type might be schema qualified and have some arguments: varchar(128)
+	typeStart, err := parser.next()
+	if err != nil {
+		return err
+	}
+	if typeStart.Kind != DBML_TOKEN_WORD && typeStart.Kind != DBML_TOKEN_QUOTED {
+		parser.pos--
+		return parser.errorf("expected a type for field '%s'", name)
+	}
+	typeEnd := typeStart
+
+	if parser.peekIs(".") {
+		parser.pos++
+		if typeEnd, err = parser.next(); err != nil {
+			return err
+		}
+	}
+
+	if parser.peekIs("(") {
+		for !parser.eof() && !parser.peekIs(")") {
+			parser.pos++
+		}
+		if typeEnd, err = parser.next(); err != nil {
+			return err
+		}
+	}
+
+	if typeStart == typeEnd && typeStart.Kind == DBML_TOKEN_QUOTED {
+		field.Type = typeStart.Text
+	} else {
+		field.Type = parser.source[typeStart.Start:typeEnd.End]
+	}
+
+	if parser.peekIs("[") {
+		settings, err := parser.parseSettings()
+		if err != nil {
+			return err
+		}
+
+		for _, setting := range settings {
+			switch setting.Key {
+			case "pk", "primary key":
+				field.IsPrimaryKey = true
+				field.IsNullable = false
+			case "not null":
+				field.IsNullable = false
+			case "null":
+				field.IsNullable = true
+			case "unique":
+				field.IsUnique = true
+			case "default":
+				field.Default = settingDefault(setting)
+			case "note":
+				field.Comment = settingNote(setting)
+			case "ref":
+				if err := parser.parseInlineRef(setting, schemaName, table.Name, name); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if err := table.AddField(field); err != nil {
+		return parser.errorf("%s", err)
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// parseInlineRef
+//
+// Inline relationships are defined on the field settings: [ref: > users.id]
+// -----------------------------------------------------------------------------
+func (parser *DbLayoutDbmlParser) parseInlineRef(
+	setting dbmlSetting,
+	schemaName string,
+	tableName string,
+	fieldName string,
+) error {
+	var err error
+
+	// reuse the same parser machinery on the value tokens
+	subParser := DbLayoutDbmlParser{
+		LayoutPtr: parser.LayoutPtr,
+		source:    parser.source,
+		tokens:    setting.Tokens,
+		pos:       0,
+	}
+
+	ref := DbRefLayout{
+		FromSchema: schemaName,
+		FromTable:  tableName,
+		FromFields: []string{fieldName},
+	}
+
+	ref.Relation, err = subParser.parseRelation()
+	if err != nil {
+		return err
+	}
+
+	ref.ToSchema, ref.ToTable, ref.ToFields, err = subParser.parseRefEndpoint()
+	if err != nil {
+		return err
+	}
+
+	parser.LayoutPtr.Refs = append(parser.LayoutPtr.Refs, &ref)
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// ParseEnum
+//
+//  Enum schema.name { value [note: '...'] }
+// -----------------------------------------------------------------------------
+func (parser *DbLayoutDbmlParser) ParseEnum() error {
+	schemaName, enumName, err := parser.parseQualifiedName()
+	if err != nil {
+		return err
+	}
+
+	schema := parser.LayoutPtr.GetOrCreateSchema(schemaName)
+	enum := schema.GetOrCreateEnum(enumName)
+
+	if err := parser.expect("{"); err != nil {
+		return err
+	}
+
+	for !parser.peekIs("}") {
+		token, err := parser.next()
+		if err != nil {
+			return err
+		}
+		if token.Kind != DBML_TOKEN_WORD && token.Kind != DBML_TOKEN_QUOTED && token.Kind != DBML_TOKEN_STRING {
+			parser.pos--
+			return parser.errorf("expected a value for enum '%s'", enumName)
+		}
+
+		value := DbEnumValueLayout{Name: token.Text}
+		if parser.peekIs("[") {
+			settings, err := parser.parseSettings()
+			if err != nil {
+				return err
+			}
+			for _, setting := range settings {
+				if setting.Key == "note" {
+					value.Comment = settingNote(setting)
+				}
+			}
+		}
+
+		enum.Values = append(enum.Values, &value)
+	}
+
+	return parser.expect("}")
+}
+
+// -----------------------------------------------------------------------------
+// ParseRef
+//
+//  Ref name: from > to [settings]
+//  Ref name { from > to [settings] ... }
+// -----------------------------------------------------------------------------
+func (parser *DbLayoutDbmlParser) ParseRef() error {
+	name := ""
+	if !parser.peekIs(":") && !parser.peekIs("{") {
+		var err error
+		if name, err = parser.parseName(); err != nil {
+			return err
+		}
+	}
+
+	if parser.peekIs(":") {
+		parser.pos++
+		return parser.parseRefDefinition(name)
+	}
+
+	if err := parser.expect("{"); err != nil {
+		return err
+	}
+
+	for !parser.peekIs("}") {
+		if parser.eof() {
+			return parser.errorf("unterminated relationship block")
+		}
+		if err := parser.parseRefDefinition(name); err != nil {
+			return err
+		}
+	}
+
+	return parser.expect("}")
+}
+
+// -----------------------------------------------------------------------------
+// Parse
+// -----------------------------------------------------------------------------
+func (parser *DbLayoutDbmlParser) Parse(source string) error {
+	var err error
+
+	parser.source = source
+	parser.pos = 0
+	parser.tokens, err = tokenizeDbml(source)
+	if err != nil {
+		return err
+	}
+
+	for !parser.eof() {
+		token, _ := parser.next()
+		if token.Kind != DBML_TOKEN_WORD {
+			parser.pos--
+			return parser.errorf("unexpected '%s'", token.Text)
+		}
+
+		switch strings.ToLower(token.Text) {
+		case "project":
+			err = parser.ParseProject()
+		case "table":
+			err = parser.ParseTable()
+		case "enum":
+			err = parser.ParseEnum()
+		case "ref":
+			err = parser.ParseRef()
+		case "tablegroup", "note":
+			// not represented on the layout
+			for !parser.eof() && !parser.peekIs("{") {
+				parser.pos++
+			}
+			err = parser.skipBlock()
+		default:
+			parser.pos--
+			err = parser.errorf("unexpected '%s'", token.Text)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// NewDbLayoutFromParsedDbml
+// -----------------------------------------------------------------------------
+func NewDbLayoutFromParsedDbml(text string) (*DbLayout, error) {
+	layout := NewDbLayout("")
+	parser := NewDbLayoutDbmlParser(&layout)
+
+	if err := parser.Parse(text); err != nil {
+		return nil, err
+	}
+
+	layout.ExtractTags()
+	layout.RebuildLookups()
+	return &layout, nil
+}
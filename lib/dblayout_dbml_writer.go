@@ -5,21 +5,107 @@ package lib
 import (
 	"fmt"
 	"io"
-	"strconv"
+	"regexp"
 	"strings"
 )
 
+var dbmlPlainNameRe = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+var dbmlPlainTypeRe = regexp.MustCompile(`^[A-Za-z0-9_]+(\.[A-Za-z0-9_]+)?(\([^)]*\))?$`)
+var dbmlPlainDefaultRe = regexp.MustCompile(`^(-?[0-9]+(\.[0-9]+)?|true|false|null|'([^']|'')*')$`)
+
 // -----------------------------------------------------------------------------
 // dbmlEscape
 //
-// Escape a DBML string by escaping single quotes and normalizing internal spaces
+// Escape a DBML string by escaping backslashes and single quotes, and
+// normalizing internal spaces
 // -----------------------------------------------------------------------------
 func dbmlEscape(input string) string {
 	normSpaces := strings.Join(strings.Fields(input), " ")
-	escaped := strings.ReplaceAll(normSpaces, "'", "\\'")
+	escaped := strings.ReplaceAll(normSpaces, "\\", "\\\\")
+	escaped = strings.ReplaceAll(escaped, "'", "\\'")
 	return "'" + escaped + "'"
 }
 
+// -----------------------------------------------------------------------------
+// dbmlNote
+//
+// Notes spanning multiple lines are written as multi-line strings so
+// paragraphs, lists and code blocks are preserved.
+// -----------------------------------------------------------------------------
+func dbmlNote(input string) string {
+	if !strings.Contains(input, "\n") {
+		return dbmlEscape(input)
+	}
+	escaped := strings.ReplaceAll(input, "\\", "\\\\")
+	escaped = strings.ReplaceAll(escaped, "'", "\\'")
+	return "'''" + escaped + "'''"
+}
+
+// -----------------------------------------------------------------------------
+// dbmlQuote
+//
+// Enclose given text between given quote, escaping backslashes and the quote
+// itself
+// -----------------------------------------------------------------------------
+func dbmlQuote(text string, quote string) string {
+	escaped := strings.ReplaceAll(text, "\\", "\\\\")
+	return quote + strings.ReplaceAll(escaped, quote, "\\"+quote) + quote
+}
+
+// -----------------------------------------------------------------------------
+// dbmlName
+//
+// Quote names that cannot be written as plain DBML identifiers
+// -----------------------------------------------------------------------------
+func dbmlName(name string) string {
+	if dbmlPlainNameRe.MatchString(name) {
+		return name
+	}
+	return dbmlQuote(name, "\"")
+}
+
+// -----------------------------------------------------------------------------
+// dbmlQualifiedName
+// -----------------------------------------------------------------------------
+func dbmlQualifiedName(schema string, name string) string {
+	if schema == NoDbSchemaLayoutName {
+		return dbmlName(name)
+	}
+	return dbmlName(schema) + "." + dbmlName(name)
+}
+
+// -----------------------------------------------------------------------------
+// dbmlDefault
+//
+// Numbers, booleans, null and strings can be written as they are, any other
+// default is treated as an expression between backticks
+// -----------------------------------------------------------------------------
+func dbmlDefault(value string) string {
+	if dbmlPlainDefaultRe.MatchString(value) {
+		if strings.HasPrefix(value, "'") {
+			unquoted := strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+			return dbmlEscape(unquoted)
+		}
+		return value
+	}
+	return dbmlQuote(value, "`")
+}
+
+// -----------------------------------------------------------------------------
+// dbmlRefEndpoint
+// -----------------------------------------------------------------------------
+func dbmlRefEndpoint(schema string, table string, fields []string) string {
+	names := make([]string, 0, len(fields))
+	for _, field := range fields {
+		names = append(names, dbmlName(field))
+	}
+
+	if len(names) == 1 {
+		return dbmlQualifiedName(schema, table) + "." + names[0]
+	}
+	return dbmlQualifiedName(schema, table) + ".(" + strings.Join(names, ", ") + ")"
+}
+
 // -----------------------------------------------------------------------------
 // PrintDbml
 //
@@ -29,16 +115,42 @@ func dbmlEscape(input string) string {
 //   - https://www.dbml.org/
 //   - https://www.dbml.org/docs/
 // -----------------------------------------------------------------------------
-func (dbLayout *DbLayout) PrintDbml(out io.Writer, addNotes bool) {
-	fmt.Fprintln(out, "Project "+dbLayout.Name+"{")
+func (dbLayout *DbLayout) PrintDbml(writer io.Writer, addNotes bool) error {
+	out := &errWriter{out: writer}
+
+	fmt.Fprintln(out, "Project "+dbmlName(dbLayout.Name)+" {")
 	fmt.Fprintln(out, "  database_type: "+dbmlEscape(dbLayout.Type))
 	if addNotes && len(dbLayout.Comment) > 0 {
-		fmt.Fprintln(out, "Note: '"+dbmlEscape(dbLayout.Comment))
+		fmt.Fprintln(out, "  Note: "+dbmlNote(dbLayout.Comment))
 	}
 	fmt.Fprintln(out, "}")
 	fmt.Fprintln(out)
 
+	dbLayout.printDbmlEnums(out, addNotes)
 	dbLayout.printDbmlTables(out, addNotes)
+	dbLayout.printDbmlRefs(out)
+
+	return out.err
+}
+
+// -----------------------------------------------------------------------------
+// printDbmlEnums
+// -----------------------------------------------------------------------------
+func (dbLayout *DbLayout) printDbmlEnums(out io.Writer, addNotes bool) {
+	for _, schemaLayout := range dbLayout.Schemas {
+		for _, enumLayout := range schemaLayout.Enums {
+			fmt.Fprintln(out, "Enum "+dbmlQualifiedName(schemaLayout.Name, enumLayout.Name)+" {")
+			for _, value := range enumLayout.Values {
+				if addNotes && value.Comment != "" {
+					fmt.Fprintf(out, "  %s [note: %s]\n", dbmlName(value.Name), dbmlNote(value.Comment))
+				} else {
+					fmt.Fprintf(out, "  %s\n", dbmlName(value.Name))
+				}
+			}
+			fmt.Fprintln(out, "}")
+			fmt.Fprintln(out)
+		}
+	}
 }
 
 // -----------------------------------------------------------------------------
@@ -46,38 +158,79 @@ func (dbLayout *DbLayout) PrintDbml(out io.Writer, addNotes bool) {
 // -----------------------------------------------------------------------------
 func (dbLayout *DbLayout) printDbmlTables(out io.Writer, addNotes bool) {
 	for _, schemaLayout := range dbLayout.Schemas {
-		// TODO: there should be a way to manage schemas in postgres and print them
-		//       in DBML, I guess... requires further investigation
-
 		for _, tableLayout := range schemaLayout.Tables {
-			fmt.Fprintln(out, "Table "+tableLayout.Name+"{")
+			fmt.Fprintln(out, "Table "+dbmlQualifiedName(schemaLayout.Name, tableLayout.Name)+" {")
 
 			maxFieldNameLen := 10
 			for _, field := range tableLayout.Fields {
-				if maxFieldNameLen < len(field.Name) {
-					maxFieldNameLen = len(field.Name)
+				if maxFieldNameLen < len(dbmlName(field.Name)) {
+					maxFieldNameLen = len(dbmlName(field.Name))
 				}
 			}
 
 			for _, field := range tableLayout.Fields {
 				typeString := field.Type
 				if field.Length > 0 {
-					typeString += strconv.Itoa(int(field.Length))
+					typeString += fmt.Sprintf("(%d)", field.Length)
 				}
-				if field.IsNullable {
-					typeString += " [not null]"
+				if !dbmlPlainTypeRe.MatchString(typeString) {
+					typeString = dbmlQuote(typeString, "\"")
 				}
 
-				//if addNotes && field.Comment != "" {
-				//	strings.ReplaceAll(typeString, "]", ", note: '" + field.Comment + "'")
-				//}
+				settings := []string{}
+				if field.IsPrimaryKey {
+					settings = append(settings, "pk")
+				}
+				if field.IsUnique {
+					settings = append(settings, "unique")
+				}
+				if !field.IsNullable {
+					settings = append(settings, "not null")
+				}
+				if field.Default != "" {
+					settings = append(settings, "default: "+dbmlDefault(field.Default))
+				}
+				if note := commentWithTags(field.Comment, field.Tags); addNotes && note != "" {
+					settings = append(settings, "note: "+dbmlNote(note))
+				}
+
+				line := fmt.Sprintf("  %-*s %s", maxFieldNameLen, dbmlName(field.Name), typeString)
+				if len(settings) > 0 {
+					line += " [" + strings.Join(settings, ", ") + "]"
+				}
+				fmt.Fprintln(out, line)
+			}
 
-				fmt.Fprintf(out, "  %-*s %s\n", maxFieldNameLen, field.Name, typeString)
+			if note := commentWithTags(tableLayout.Comment, tableLayout.Tags); addNotes && note != "" {
+				fmt.Fprintln(out)
+				fmt.Fprintln(out, "  Note: "+dbmlNote(note))
 			}
 
 			fmt.Fprintln(out, "}")
 			fmt.Fprintln(out)
 		}
 	}
+}
+
+// -----------------------------------------------------------------------------
+// printDbmlRefs
+// -----------------------------------------------------------------------------
+func (dbLayout *DbLayout) printDbmlRefs(out io.Writer) {
+	for _, ref := range dbLayout.Refs {
+		line := "Ref"
+		if ref.Name != "" {
+			line += " " + dbmlName(ref.Name)
+		}
+		line += ": " + dbmlRefEndpoint(ref.FromSchema, ref.FromTable, ref.FromFields)
+		line += " " + ref.Relation + " "
+		line += dbmlRefEndpoint(ref.ToSchema, ref.ToTable, ref.ToFields)
+		if ref.Settings != "" {
+			line += " [" + ref.Settings + "]"
+		}
+		fmt.Fprintln(out, line)
+	}
 
+	if len(dbLayout.Refs) > 0 {
+		fmt.Fprintln(out)
+	}
 }
@@ -0,0 +1,30 @@
+// Copyright (C) 2021 Pau Sanchez
+package lib
+
+import "fmt"
+
+// -----------------------------------------------------------------------------
+// DbLayoutDiagnostic
+//
+// Problem found while parsing a documentation file. Diagnostics are not fatal,
+// the parser will skip whatever it does not understand and keep going.
+// -----------------------------------------------------------------------------
+type DbLayoutDiagnostic struct {
+	File    string // empty when parsing a string
+	Line    int    // starting at 1
+	Column  int    // starting at 1
+	Message string
+}
+
+// -----------------------------------------------------------------------------
+// Error
+//
+// Formats the diagnostic as file:line:column: message
+// -----------------------------------------------------------------------------
+func (diagnostic DbLayoutDiagnostic) Error() string {
+	file := diagnostic.File
+	if file == "" {
+		file = "<input>"
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", file, diagnostic.Line, diagnostic.Column, diagnostic.Message)
+}
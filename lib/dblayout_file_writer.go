@@ -0,0 +1,106 @@
+// Copyright (C) 2021 Pau Sanchez
+package lib
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Suffix of the previous version of a file when writing with backup
+const BackupSuffix = ".bak"
+
+// -----------------------------------------------------------------------------
+// errWriter
+//
+// Writer that remembers the first error, so printing functions can write
+// everything without checking each call and return the error at the end.
+// Nothing else is written after an error.
+// -----------------------------------------------------------------------------
+type errWriter struct {
+	out io.Writer
+	err error
+}
+
+// -----------------------------------------------------------------------------
+// Write
+// -----------------------------------------------------------------------------
+func (w *errWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	n, err := w.out.Write(p)
+	w.err = err
+	return n, err
+}
+
+// -----------------------------------------------------------------------------
+// WriteFileAtomic
+//
+// Write a file through given function without ever leaving it half written:
+// contents are written to a temporary file in the same directory, which
+// replaces the file only on success. Otherwise the existing file is left
+// untouched. With backup, the previous version (if any) is kept with
+// BackupSuffix.
+// -----------------------------------------------------------------------------
+func WriteFileAtomic(path string, backup bool, write func(io.Writer) error) error {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+
+	// keep permissions of the existing file
+	mode := os.FileMode(0644)
+	info, err := os.Stat(path)
+	if err == nil {
+		mode = info.Mode().Perm()
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	tmpFile, err := ioutil.TempFile(dir, "."+base+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := tmpFile.Name()
+	renamed := false
+	defer func() {
+		if !renamed {
+			tmpFile.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if err := write(tmpFile); err != nil {
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		return err
+	}
+
+	if backup && info != nil {
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path+BackupSuffix, contents, mode); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+
+	renamed = true
+	return nil
+}
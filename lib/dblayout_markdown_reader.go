@@ -2,10 +2,11 @@
 package lib
 
 import (
-	"bufio"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -15,10 +16,26 @@ const (
 	ITEM_ID_SCHEMA  = 2
 	ITEM_ID_TABLE   = 3
 	ITEM_ID_FIELD   = 4
+
+	// sequences, triggers and policies
+	ITEM_ID_SEQUENCE = 5
+	ITEM_ID_TRIGGER  = 6
+	ITEM_ID_POLICY   = 7
 )
 
 type ItemIdentifier int
 
+var fieldLineRe = regexp.MustCompile(`^\-\s+[^\s\[]+\s*\[.*\]\s*$`)
+var fieldRe = regexp.MustCompile(`^\-\s*([^\s\[]+)\s*(?:\[(.*)\])?`)
+var layoutHeadingRe = regexp.MustCompile(`^(.*?)\s*(?:\(([^()]*)\))?$`)
+var tableDelimiterCellRe = regexp.MustCompile(`^:?-+:?$`)
+
+// defaults follow the type and tags of fields: [type? @tag default expression]
+const fieldDefaultSeparator = " default "
+
+// columns of the tables of fields written by the md-table format
+var fieldsTableHeader = []string{"name", "type", "nullable", "pk", "default", "description"}
+
 // -----------------------------------------------------------------------------
 // DbLayoutTextParser
 //
@@ -30,39 +47,189 @@ type DbLayoutTextParser struct {
 	TablePtr  *DbTableLayout
 	FieldPtr  *DbFieldLayout
 
-	// previous comment lines
+	SequencePtr *DbSequenceLayout
+	TriggerPtr  *DbTriggerLayout
+	PolicyPtr   *DbPolicyLayout
+
+	// previous comment lines, and where they start
 	LastItemParsed ItemIdentifier
 	Comment        []string
+	CommentToken   *MarkdownLineToken
+
+	// indentation of the last field line
+	FieldIndent int
+
+	// column where the text of the last item starts, lines indented 4 more
+	// columns than that are indented code
+	ItemIndent int
+
+	// lowercased column names while parsing a table of fields, nil otherwise
+	GridColumns []string
+
+	// user-authored lines kept as they are, once a verbatim block starts all
+	// lines belong to it until its closing marker or the next schema or table.
+	// Blocks started by a heading are closed by a stray closing marker.
+	Verbatim          []string
+	InVerbatim        bool
+	VerbatimByHeading bool
+
+	// markdown files need to be unescaped, text files don't
+	Unescape EscapeFunc
+
+	// problems found while parsing
+	Diagnostics []DbLayoutDiagnostic
 }
 
 // -----------------------------------------------------------------------------
 // NewDbLayoutTextParser
 // -----------------------------------------------------------------------------
-func NewDbLayoutTextParser(layoutPtr *DbLayout) DbLayoutTextParser {
+func NewDbLayoutTextParser(layoutPtr *DbLayout, unescape EscapeFunc) DbLayoutTextParser {
 	return DbLayoutTextParser{
 		LayoutPtr:      layoutPtr,
 		SchemaPtr:      nil,
 		TablePtr:       nil,
 		FieldPtr:       nil,
+		SequencePtr:    nil,
+		TriggerPtr:     nil,
+		PolicyPtr:      nil,
 		LastItemParsed: ITEM_ID_UNKNOWN,
 		Comment:        []string{},
+		FieldIndent:    0,
+		ItemIndent:     0,
+		GridColumns:    nil,
+		Verbatim:          []string{},
+		InVerbatim:        false,
+		VerbatimByHeading: false,
+		Unescape:          unescape,
+		CommentToken:      nil,
+		Diagnostics:       []DbLayoutDiagnostic{},
 	}
 }
 
 // -----------------------------------------------------------------------------
-// NewDbLayoutTextParser
+// addDiagnostic
+// -----------------------------------------------------------------------------
+func (layoutParser *DbLayoutTextParser) addDiagnostic(
+	token MarkdownLineToken,
+	format string,
+	args ...interface{},
+) {
+	layoutParser.Diagnostics = append(layoutParser.Diagnostics, DbLayoutDiagnostic{
+		Line:    token.Line,
+		Column:  token.Indent + 1,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// -----------------------------------------------------------------------------
+// TextUnescape
+//
+// Undo TextEscape
+// -----------------------------------------------------------------------------
+func TextUnescape(text string) string {
+	if textLeadingDashRe.MatchString(text) && strings.HasPrefix(text, "\\") {
+		return text[1:]
+	}
+	return text
+}
+
+// -----------------------------------------------------------------------------
+// MarkdownUnescape
+//
+// Remove backslashes from markdown escapes (a backslash followed by an ASCII
+// punctuation character). Code spans are left untouched.
+// -----------------------------------------------------------------------------
+func MarkdownUnescape(text string) string {
+	buff := strings.Builder{}
+	buff.Grow(len(text))
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+
+		if c == '\\' && i+1 < len(text) && isAsciiPunctuation(text[i+1]) {
+			buff.WriteByte(text[i+1])
+			i++
+			continue
+		}
+
+		// code span: copy everything up to the closing backtick run
+		if c == '`' {
+			run := 1
+			for i+run < len(text) && text[i+run] == '`' {
+				run++
+			}
+
+			delimiter := text[i : i+run]
+			closing := indexBacktickRun(text[i+run:], run)
+			if closing >= 0 {
+				end := i + run + closing + run
+				buff.WriteString(text[i:end])
+				i = end - 1
+			} else {
+				buff.WriteString(delimiter)
+				i += run - 1
+			}
+			continue
+		}
+
+		buff.WriteByte(c)
+	}
+
+	return buff.String()
+}
+
+// -----------------------------------------------------------------------------
+// isAsciiPunctuation
+// -----------------------------------------------------------------------------
+func isAsciiPunctuation(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+// -----------------------------------------------------------------------------
+// indexBacktickRun
+//
+// Returns the position of the first run of exactly n backticks, or -1
+// -----------------------------------------------------------------------------
+func indexBacktickRun(text string, n int) int {
+	for i := 0; i < len(text); i++ {
+		if text[i] != '`' {
+			continue
+		}
+
+		run := 1
+		for i+run < len(text) && text[i+run] == '`' {
+			run++
+		}
+		if run == n {
+			return i
+		}
+		i += run - 1
+	}
+	return -1
+}
+
 // -----------------------------------------------------------------------------
-func (layoutParser *DbLayoutTextParser) ParseHeader(line string) {
-	parts := strings.Split(line, " ")
+// ParseHeader
+// -----------------------------------------------------------------------------
+func (layoutParser *DbLayoutTextParser) ParseHeader(token MarkdownLineToken) {
+	layoutParser.ItemIndent = token.Indent
+
+	name := layoutParser.Unescape(token.Text)
+	if name == "" {
+		layoutParser.addDiagnostic(token, "ignoring heading without a name")
+		layoutParser.LastItemParsed = ITEM_ID_UNKNOWN
+		return
+	}
 
-	switch strings.Count(parts[0], "#") {
-	case 1: // # database_name
-		layoutParser.LayoutPtr.Name = parts[1]
-		layoutParser.LayoutPtr.Type = strings.Trim(parts[2], "()")
+	switch token.Level {
+	case 1: // # database_name (type)
+		m := layoutHeadingRe.FindStringSubmatch(name)
+		layoutParser.LayoutPtr.Name = m[1]
+		layoutParser.LayoutPtr.Type = m[2]
 		layoutParser.LastItemParsed = ITEM_ID_LAYOUT
 
 	case 2: // ## schema_name
-		newSchema := NewDbSchemaLayout(parts[1])
+		newSchema := NewDbSchemaLayout(name)
 		layoutParser.SchemaPtr = &newSchema
 		layoutParser.LayoutPtr.Schemas = append(layoutParser.LayoutPtr.Schemas, layoutParser.SchemaPtr)
 		layoutParser.LastItemParsed = ITEM_ID_SCHEMA
@@ -74,13 +241,54 @@ func (layoutParser *DbLayoutTextParser) ParseHeader(line string) {
 			layoutParser.LayoutPtr.Schemas = append(layoutParser.LayoutPtr.Schemas, layoutParser.SchemaPtr)
 		}
 
-		newTable := NewDbTableLayout(parts[1])
+		newTable := NewDbTableLayout(name)
 		layoutParser.TablePtr = &newTable
 		layoutParser.SchemaPtr.Tables = append(layoutParser.SchemaPtr.Tables, layoutParser.TablePtr)
 		layoutParser.LastItemParsed = ITEM_ID_TABLE
 
 	default:
-		fmt.Printf("Ignoring line. I don't know how to parse this: %s\n", line)
+		layoutParser.addDiagnostic(token, "ignoring heading of level %d: %s", token.Level, token.Text)
+	}
+}
+
+// -----------------------------------------------------------------------------
+// AssignVerbatimToLastItem
+//
+// Verbatim blocks that do not belong to any item go to the layout. Blocks that
+// were not closed get the closing marker, so they end in the same place when
+// the written file is read again.
+// -----------------------------------------------------------------------------
+func (layoutParser *DbLayoutTextParser) AssignVerbatimToLastItem() {
+	lines := layoutParser.Verbatim
+	if layoutParser.InVerbatim && len(lines) > 0 {
+		indent := lines[0][:len(lines[0])-len(strings.TrimLeft(lines[0], " \t"))]
+		lines = append(strings.Split(strings.TrimRight(strings.Join(lines, "\n"), "\n"), "\n"), indent+VerbatimEndMarker)
+	}
+
+	verbatim := strings.Trim(strings.Join(lines, "\n"), "\n")
+	layoutParser.Verbatim = []string{}
+	layoutParser.InVerbatim = false
+	layoutParser.VerbatimByHeading = false
+
+	if verbatim == "" {
+		return
+	}
+
+	switch layoutParser.LastItemParsed {
+	case ITEM_ID_SCHEMA:
+		layoutParser.SchemaPtr.Verbatim = verbatim
+	case ITEM_ID_TABLE:
+		layoutParser.TablePtr.Verbatim = verbatim
+	case ITEM_ID_FIELD:
+		layoutParser.FieldPtr.Verbatim = verbatim
+	case ITEM_ID_SEQUENCE:
+		layoutParser.SequencePtr.Verbatim = verbatim
+	case ITEM_ID_TRIGGER:
+		layoutParser.TriggerPtr.Verbatim = verbatim
+	case ITEM_ID_POLICY:
+		layoutParser.PolicyPtr.Verbatim = verbatim
+	default:
+		layoutParser.LayoutPtr.Verbatim = verbatim
 	}
 }
 
@@ -88,7 +296,7 @@ func (layoutParser *DbLayoutTextParser) ParseHeader(line string) {
 // AssignCommentsToLastItem
 // -----------------------------------------------------------------------------
 func (layoutParser *DbLayoutTextParser) AssignCommentsToLastItem() {
-	comment := strings.TrimSpace(strings.Join(layoutParser.Comment, " "))
+	comment := NormalizeComment(layoutParser.Comment, layoutParser.Unescape)
 
 	switch layoutParser.LastItemParsed {
 	case ITEM_ID_LAYOUT:
@@ -99,122 +307,543 @@ func (layoutParser *DbLayoutTextParser) AssignCommentsToLastItem() {
 		layoutParser.TablePtr.Comment = comment
 	case ITEM_ID_FIELD:
 		layoutParser.FieldPtr.Comment = comment
+	case ITEM_ID_SEQUENCE:
+		layoutParser.SequencePtr.Comment = comment
+	case ITEM_ID_TRIGGER:
+		layoutParser.TriggerPtr.Comment = comment
+	case ITEM_ID_POLICY:
+		layoutParser.PolicyPtr.Comment = comment
 	default:
 		if comment != "" {
-			fmt.Println("ERROR: Don't know who to assign this comments to:", layoutParser.Comment)
+			layoutParser.addDiagnostic(*layoutParser.CommentToken, "comment does not belong to any item")
 		}
 	}
 
 	layoutParser.Comment = []string{}
+	layoutParser.CommentToken = nil
+
+	layoutParser.AssignVerbatimToLastItem()
 }
 
 // -----------------------------------------------------------------------------
 // ParseField
 //
-//  - field_name [type / ....]
+//  - field_name [type? @tag @tag:value default expression]
 // -----------------------------------------------------------------------------
-func (layoutParser *DbLayoutTextParser) ParseField(line string) {
-	var name string
-	var typeString string
+func (layoutParser *DbLayoutTextParser) ParseField(token MarkdownLineToken) {
+	m := fieldRe.FindStringSubmatch(token.Text)
+	if m == nil {
+		layoutParser.addDiagnostic(token, "ignoring field without a name")
+		layoutParser.LastItemParsed = ITEM_ID_UNKNOWN
+		return
+	}
 
-	re := regexp.MustCompile(`\-\s+([^\s]+)\s*(\[[^\]]+\])?`)
-	m := re.FindStringSubmatch(line)
-	if len(m) == 2 {
-		name = m[1]
-	} else if len(m) == 3 {
-		name = m[1]
-		typeString = strings.Trim(strings.TrimSpace(m[2]), "[]")
+	if layoutParser.TablePtr == nil {
+		layoutParser.addDiagnostic(token, "ignoring field '%s' that does not belong to any table", m[1])
+		layoutParser.LastItemParsed = ITEM_ID_UNKNOWN
+		return
 	}
 
+	name := layoutParser.Unescape(m[1])
+	typeString := layoutParser.Unescape(strings.TrimSpace(m[2]))
+
 	field := NewDbFieldLayout(name)
 	layoutParser.FieldPtr = &field
 	layoutParser.TablePtr.Fields = append(layoutParser.TablePtr.Fields, layoutParser.FieldPtr)
 	layoutParser.LastItemParsed = ITEM_ID_FIELD
+	layoutParser.FieldIndent = token.Indent
+	layoutParser.ItemIndent = token.Indent + 2
 
-	// TODO: parse type string when it becomes more complex, by splitting /
-	field.Type = strings.Trim(typeString, "?")
+	// the default goes last, since it can be any expression
+	if index := strings.Index(typeString, fieldDefaultSeparator); index >= 0 {
+		field.Default = strings.TrimSpace(typeString[index+len(fieldDefaultSeparator):])
+		typeString = typeString[:index]
+	}
+
+	// tags can follow the type: [type? @tag @tag:value]
+	typeString, field.Tags = ParseTags(typeString)
+	field.Type = strings.TrimSuffix(typeString, "?")
 	field.IsNullable = strings.HasSuffix(typeString, "?")
 }
 
 // -----------------------------------------------------------------------------
-// ParseLine
+// ParseObject
+//
+//  - sequence name [definition]
+//  - trigger name [definition]
+//  - policy name [definition]
+//
+// Sequences belong to the current schema, triggers and policies to the
+// current table.
+// -----------------------------------------------------------------------------
+func (layoutParser *DbLayoutTextParser) ParseObject(token MarkdownLineToken) {
+	m := objectLineRe.FindStringSubmatch(token.Text)
+	kind := m[1]
+	name := layoutParser.Unescape(m[2])
+	definition := layoutParser.Unescape(strings.TrimSpace(m[3]))
+
+	layoutParser.LastItemParsed = ITEM_ID_UNKNOWN
+	layoutParser.FieldIndent = token.Indent
+	layoutParser.ItemIndent = token.Indent + 2
+
+	var err error
+
+	switch kind {
+	case OBJECT_KIND_SEQUENCE:
+		if layoutParser.SchemaPtr == nil {
+			newSchema := NewDbSchemaLayout(NoDbSchemaLayoutName)
+			layoutParser.SchemaPtr = &newSchema
+			layoutParser.LayoutPtr.Schemas = append(layoutParser.LayoutPtr.Schemas, layoutParser.SchemaPtr)
+		}
+
+		sequence := NewDbSequenceLayout(name)
+		err = sequence.ParseDefinition(definition)
+		layoutParser.SequencePtr = &sequence
+		layoutParser.SchemaPtr.Sequences = append(layoutParser.SchemaPtr.Sequences, layoutParser.SequencePtr)
+		layoutParser.LastItemParsed = ITEM_ID_SEQUENCE
+
+	case OBJECT_KIND_TRIGGER, OBJECT_KIND_POLICY:
+		if layoutParser.TablePtr == nil {
+			layoutParser.addDiagnostic(token, "ignoring %s '%s' that does not belong to any table", kind, name)
+			return
+		}
+
+		if kind == OBJECT_KIND_TRIGGER {
+			trigger := NewDbTriggerLayout(name)
+			err = trigger.ParseDefinition(definition)
+			layoutParser.TriggerPtr = &trigger
+			layoutParser.TablePtr.Triggers = append(layoutParser.TablePtr.Triggers, layoutParser.TriggerPtr)
+			layoutParser.LastItemParsed = ITEM_ID_TRIGGER
+		} else {
+			policy := NewDbPolicyLayout(name)
+			err = policy.ParseDefinition(definition)
+			layoutParser.PolicyPtr = &policy
+			layoutParser.TablePtr.Policies = append(layoutParser.TablePtr.Policies, layoutParser.PolicyPtr)
+			layoutParser.LastItemParsed = ITEM_ID_POLICY
+		}
+	}
+
+	// definitions are read from the database anyway
+	if err != nil {
+		layoutParser.addDiagnostic(token, "%s", err)
+	}
+}
+
+// -----------------------------------------------------------------------------
+// splitTableRow
+//
+// Split a markdown table row in trimmed cells. Escaped pipes belong to the
+// cell and are unescaped.
+// -----------------------------------------------------------------------------
+func splitTableRow(text string) []string {
+	text = strings.TrimPrefix(strings.TrimSpace(text), "|")
+	if strings.HasSuffix(text, "|") && !strings.HasSuffix(text, "\\|") {
+		text = strings.TrimSuffix(text, "|")
+	}
+
+	cells := []string{}
+	cell := strings.Builder{}
+	for i := 0; i < len(text); i++ {
+		switch {
+		case text[i] == '\\' && i+1 < len(text) && text[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case text[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(text[i])
+		}
+	}
+
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// -----------------------------------------------------------------------------
+// fieldsTableColumns
+//
+// Lowercase column names of a table header row
+// -----------------------------------------------------------------------------
+func (layoutParser *DbLayoutTextParser) fieldsTableColumns(token MarkdownLineToken) []string {
+	columns := []string{}
+	for _, cell := range splitTableRow(token.Text) {
+		columns = append(columns, strings.ToLower(layoutParser.Unescape(cell)))
+	}
+	return columns
+}
+
+// -----------------------------------------------------------------------------
+// containsString
+// -----------------------------------------------------------------------------
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// -----------------------------------------------------------------------------
+// ParseFieldsTableHeader
+//
+// A table anywhere in the section of a table is a table of fields if it has,
+// at least, a name and a type column. Tables inside the comment of a field or
+// object (indented deeper than it) are kept in the comment. Returns false
+// otherwise.
+// -----------------------------------------------------------------------------
+func (layoutParser *DbLayoutTextParser) ParseFieldsTableHeader(token MarkdownLineToken) bool {
+	switch layoutParser.LastItemParsed {
+	case ITEM_ID_TABLE:
+	case ITEM_ID_FIELD, ITEM_ID_TRIGGER, ITEM_ID_POLICY:
+		if token.Indent > layoutParser.FieldIndent {
+			return false
+		}
+	default:
+		return false
+	}
+
+	columns := layoutParser.fieldsTableColumns(token)
+	if !containsString(columns, "name") || !containsString(columns, "type") {
+		return false
+	}
+
+	// rows are fields of the table, no matter what came before them
+	layoutParser.AssignCommentsToLastItem()
+	layoutParser.LastItemParsed = ITEM_ID_TABLE
+	layoutParser.GridColumns = columns
+	return true
+}
+
+// -----------------------------------------------------------------------------
+// ParseFieldsTableRow
+//
+//  | field_name | type | yes | no | default | comment<br>more comment |
+// -----------------------------------------------------------------------------
+func (layoutParser *DbLayoutTextParser) ParseFieldsTableRow(token MarkdownLineToken) {
+	cells := splitTableRow(token.Text)
+
+	isDelimiter := true
+	for _, cell := range cells {
+		isDelimiter = isDelimiter && tableDelimiterCellRe.MatchString(cell)
+	}
+	if isDelimiter {
+		return
+	}
+
+	field := NewDbFieldLayout("")
+	comment := []string{}
+	for i, cell := range cells {
+		if i >= len(layoutParser.GridColumns) {
+			layoutParser.addDiagnostic(token, "ignoring cells beyond the table header")
+			break
+		}
+
+		lines := strings.Split(strings.ReplaceAll(cell, "<br>", "\n"), "\n")
+		text := NormalizeComment(lines, layoutParser.Unescape)
+
+		switch layoutParser.GridColumns[i] {
+		case "name":
+			field.Name = text
+		case "type":
+			text, field.Tags = ParseTags(text)
+			field.Type = strings.TrimSuffix(text, "?")
+			field.IsNullable = field.IsNullable || strings.HasSuffix(text, "?")
+		case "nullable":
+			field.IsNullable = strings.EqualFold(text, "yes")
+		case "pk":
+			field.IsPrimaryKey = strings.EqualFold(text, "yes")
+		case "default":
+			field.Default = text
+		case "description", "comment":
+			comment = lines
+		}
+	}
+
+	if field.Name == "" {
+		layoutParser.addDiagnostic(token, "ignoring field without a name")
+		return
+	}
+
+	// the description is assigned as any other comment, so whatever follows
+	// the table is appended to the last field
+	if layoutParser.LastItemParsed == ITEM_ID_FIELD {
+		layoutParser.AssignCommentsToLastItem()
+	}
+	layoutParser.Comment = comment
+	commentToken := token
+	layoutParser.CommentToken = &commentToken
+
+	layoutParser.FieldPtr = &field
+	layoutParser.TablePtr.Fields = append(layoutParser.TablePtr.Fields, layoutParser.FieldPtr)
+	layoutParser.LastItemParsed = ITEM_ID_FIELD
+	layoutParser.FieldIndent = token.Indent
+	layoutParser.ItemIndent = token.Indent
+}
+
+// -----------------------------------------------------------------------------
+// hasOpenComment
+//
+// Returns true if some text has been read since the last item
+// -----------------------------------------------------------------------------
+func (layoutParser *DbLayoutTextParser) hasOpenComment() bool {
+	for _, line := range layoutParser.Comment {
+		if strings.TrimSpace(line) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// -----------------------------------------------------------------------------
+// isFieldLine
+//
+// Lines like "- name [type]" are always fields. Lines starting with "-" but
+// without a type might be a list item inside a comment instead: on field
+// comments they are list items when indented deeper than the field, otherwise
+// when there is already some comment text before them.
+// -----------------------------------------------------------------------------
+func (layoutParser *DbLayoutTextParser) isFieldLine(token MarkdownLineToken) bool {
+	if fieldLineRe.MatchString(token.Text) {
+		return true
+	}
+
+	if layoutParser.isListItemOwner() {
+		return token.Indent <= layoutParser.FieldIndent
+	}
+
+	return !layoutParser.hasOpenComment()
+}
+
+// -----------------------------------------------------------------------------
+// isObjectLine
+//
+// Lines like "- trigger name [definition]" are sequences, triggers or
+// policies, unless they are list items of a comment (indented deeper than the
+// field or object they belong to)
+// -----------------------------------------------------------------------------
+func (layoutParser *DbLayoutTextParser) isObjectLine(token MarkdownLineToken) bool {
+	if !objectLineRe.MatchString(token.Text) {
+		return false
+	}
+
+	return !layoutParser.isListItemOwner() || token.Indent <= layoutParser.FieldIndent
+}
+
+// -----------------------------------------------------------------------------
+// isListItemOwner
+//
+// Fields and objects are list items themselves, so the list items of their
+// comments are told apart by their indentation
+// -----------------------------------------------------------------------------
+func (layoutParser *DbLayoutTextParser) isListItemOwner() bool {
+	switch layoutParser.LastItemParsed {
+	case ITEM_ID_FIELD, ITEM_ID_SEQUENCE, ITEM_ID_TRIGGER, ITEM_ID_POLICY:
+		return true
+	}
+	return false
+}
+
+// -----------------------------------------------------------------------------
+// isIndentedCode
+//
+// Lines indented 4 or more columns beyond the text of the item they belong to
+// are part of an indented code block, so headings, lists and tables in them
+// are just comment text
 // -----------------------------------------------------------------------------
-func (layoutParser *DbLayoutTextParser) ParseLine(line string) {
+func (layoutParser *DbLayoutTextParser) isIndentedCode(token MarkdownLineToken) bool {
+	switch token.Kind {
+	case MD_TOKEN_HEADING, MD_TOKEN_LIST, MD_TOKEN_TABLE_ROW:
+		return token.Indent >= layoutParser.ItemIndent+4
+	}
+	return false
+}
+
+// -----------------------------------------------------------------------------
+// ParseToken
+// -----------------------------------------------------------------------------
+func (layoutParser *DbLayoutTextParser) ParseToken(token MarkdownLineToken) {
+	if layoutParser.isIndentedCode(token) {
+		token.Kind = MD_TOKEN_TEXT
+	}
+
+	if layoutParser.InVerbatim && !layoutParser.endsVerbatim(token) {
+		layoutParser.Verbatim = append(layoutParser.Verbatim, token.Raw)
+		layoutParser.InVerbatim = !layoutParser.closesVerbatim(token)
+		return
+	}
+
+	// a table of fields ends on the first line that is not a row
+	if token.Kind != MD_TOKEN_TABLE_ROW {
+		layoutParser.GridColumns = nil
+	}
+
 	switch {
-	case line == "":
-		// skip
+	case token.Kind == MD_TOKEN_FRONT_MATTER:
+		layoutParser.LayoutPtr.FrontMatter = strings.TrimPrefix(
+			layoutParser.LayoutPtr.FrontMatter+"\n"+token.Raw, "\n",
+		)
+
+	case token.Kind == MD_TOKEN_VERBATIM,
+		token.Kind == MD_TOKEN_HEADING && token.Level > 3:
+		if len(layoutParser.Verbatim) > 0 {
+			layoutParser.Verbatim = append(layoutParser.Verbatim, "")
+		}
+		layoutParser.InVerbatim = true
+		layoutParser.VerbatimByHeading = token.Kind == MD_TOKEN_HEADING
+		layoutParser.Verbatim = append(layoutParser.Verbatim, token.Raw)
+
+	case token.Kind == MD_TOKEN_BLANK:
+		layoutParser.Comment = append(layoutParser.Comment, "")
+
+	case token.Kind == MD_TOKEN_HEADING:
+		layoutParser.AssignCommentsToLastItem()
+		layoutParser.ParseHeader(token)
+
+	case token.Kind == MD_TOKEN_TABLE_ROW && layoutParser.GridColumns != nil:
+		layoutParser.ParseFieldsTableRow(token)
+
+	case token.Kind == MD_TOKEN_TABLE_ROW && layoutParser.ParseFieldsTableHeader(token):
+		// nothing else to do, rows will follow
 
-	case strings.HasPrefix(line, "#"):
+	case token.Kind == MD_TOKEN_LIST && layoutParser.isObjectLine(token):
 		layoutParser.AssignCommentsToLastItem()
-		layoutParser.ParseHeader(line)
+		layoutParser.ParseObject(token)
 
-	case strings.HasPrefix(line, "-"):
+	case token.Kind == MD_TOKEN_LIST && layoutParser.isFieldLine(token):
 		layoutParser.AssignCommentsToLastItem()
-		layoutParser.ParseField(line)
+		layoutParser.ParseField(token)
 
 	default:
-		layoutParser.Comment = append(layoutParser.Comment, line)
+		if layoutParser.CommentToken == nil {
+			commentToken := token
+			layoutParser.CommentToken = &commentToken
+		}
+		layoutParser.Comment = append(layoutParser.Comment, token.Raw)
+	}
+}
+
+// -----------------------------------------------------------------------------
+// endsVerbatim
+//
+// Verbatim blocks that are not closed end on the next schema or table, so
+// lists, tables or anything else inside the block are kept.
+// -----------------------------------------------------------------------------
+func (layoutParser *DbLayoutTextParser) endsVerbatim(token MarkdownLineToken) bool {
+	return token.Kind == MD_TOKEN_HEADING && token.Level <= 3
+}
+
+// -----------------------------------------------------------------------------
+// closesVerbatim
+//
+// Whether the line is the closing marker of the current verbatim block. Blocks
+// started by a heading can only be closed by a marker that does not belong to
+// a block between markers inside them.
+// -----------------------------------------------------------------------------
+func (layoutParser *DbLayoutTextParser) closesVerbatim(token MarkdownLineToken) bool {
+	if token.Text != VerbatimEndMarker {
+		return false
+	}
+	if layoutParser.VerbatimByHeading {
+		return token.Kind == MD_TOKEN_TEXT
 	}
+	return token.Kind == MD_TOKEN_VERBATIM
 }
 
 // -----------------------------------------------------------------------------
 // NewDbLayoutFromParsedString
 //
 // It will try to parse things with a simple algorithm, if the lines are really
-// malformed, then it won't be able to do anything
+// malformed, then it won't be able to do anything. Whatever cannot be parsed
+// is skipped and reported on the returned diagnostics. Comments are unescaped
+// with TextUnescape.
+// -----------------------------------------------------------------------------
+func NewDbLayoutFromParsedString(text string) (*DbLayout, []DbLayoutDiagnostic, error) {
+	return newDbLayoutFromParsedText(text, TextUnescape)
+}
+
+// -----------------------------------------------------------------------------
+// NewDbLayoutFromParsedMarkdown
+//
+// Same as NewDbLayoutFromParsedString but markdown escapes are removed
+// -----------------------------------------------------------------------------
+func NewDbLayoutFromParsedMarkdown(text string) (*DbLayout, []DbLayoutDiagnostic, error) {
+	return newDbLayoutFromParsedText(text, MarkdownUnescape)
+}
+
+// -----------------------------------------------------------------------------
+// newDbLayoutFromParsedText
 // -----------------------------------------------------------------------------
-func NewDbLayoutFromParsedString(text string) (*DbLayout, error) {
+func newDbLayoutFromParsedText(
+	text string,
+	unescape EscapeFunc,
+) (
+	*DbLayout,
+	[]DbLayoutDiagnostic,
+	error,
+) {
 	layout := NewDbLayout("")
-	layoutParser := NewDbLayoutTextParser(&layout)
+	layoutParser := NewDbLayoutTextParser(&layout, unescape)
 
-	scanner := bufio.NewScanner(strings.NewReader(text))
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		layoutParser.ParseLine(line)
+	tokens, diagnostics, err := TokenizeMarkdownLines(text)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, token := range tokens {
+		layoutParser.ParseToken(token)
 	}
 
 	layoutParser.AssignCommentsToLastItem()
+	layout.ExtractStats()
+	layout.ExtractProfiles()
+	layout.ExtractTags()
 	layout.RebuildLookups()
 
-	err := scanner.Err()
-	if err != nil {
-		return nil, err
-	}
+	diagnostics = append(diagnostics, layoutParser.Diagnostics...)
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		return diagnostics[i].Line < diagnostics[j].Line
+	})
 
-	return &layout, nil
+	return &layout, diagnostics, nil
 }
 
 // -----------------------------------------------------------------------------
 // NewDbLayoutFromParsedFile
+//
+// Parse given file depending on its extension. Returned diagnostics will have
+// the file set. Directories are read as split documentation.
 // -----------------------------------------------------------------------------
-func NewDbLayoutFromParsedFile(path string) (*DbLayout, error) {
+func NewDbLayoutFromParsedFile(path string) (*DbLayout, []DbLayoutDiagnostic, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return NewDbLayoutFromParsedDir(path)
+	}
+
 	byteContents, err := ioutil.ReadFile(path)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	var contents string
-	lpath := strings.ToLower(path)
-	if strings.HasSuffix(lpath, ".md") || strings.HasSuffix(lpath, ".mardown") {
-		// unescape backslashes
-		buff := strings.Builder{}
-		buff.Grow(len(byteContents))
-
-		lastSkipped := false
-		for _, c := range byteContents {
-			if c == '\\' && !lastSkipped {
-				lastSkipped = true
-				continue
-			}
+	var layout *DbLayout
+	var diagnostics []DbLayoutDiagnostic
 
-			lastSkipped = false
-			buff.WriteByte(c)
-		}
+	lpath := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(lpath, ".dbml"):
+		layout, err = NewDbLayoutFromParsedDbml(string(byteContents))
+	case strings.HasSuffix(lpath, ".csv"):
+		layout, diagnostics, err = NewDbLayoutFromParsedCsv(string(byteContents), ',')
+	case strings.HasSuffix(lpath, ".tsv"):
+		layout, diagnostics, err = NewDbLayoutFromParsedCsv(string(byteContents), '\t')
+	case strings.HasSuffix(lpath, ".md") || strings.HasSuffix(lpath, ".markdown"):
+		layout, diagnostics, err = NewDbLayoutFromParsedMarkdown(string(byteContents))
+	default:
+		layout, diagnostics, err = NewDbLayoutFromParsedString(string(byteContents))
+	}
 
-		contents = buff.String()
-	} else {
-		contents = string(byteContents)
+	for i := range diagnostics {
+		diagnostics[i].File = path
 	}
 
-	return NewDbLayoutFromParsedString(contents)
+	return layout, diagnostics, err
 }
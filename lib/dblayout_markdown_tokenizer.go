@@ -0,0 +1,161 @@
+// Copyright (C) 2021 Pau Sanchez
+package lib
+
+import (
+	"bufio"
+	"regexp"
+	"strings"
+)
+
+const (
+	MD_TOKEN_BLANK   = 0
+	MD_TOKEN_HEADING = 1
+	MD_TOKEN_LIST    = 2 // lines starting with "-", either fields or list items
+	MD_TOKEN_TEXT    = 3
+	MD_TOKEN_CODE    = 4 // fenced code block lines, including the fences
+
+	MD_TOKEN_FRONT_MATTER = 5 // --- yaml front matter at the top of the file ---
+	MD_TOKEN_VERBATIM     = 6 // lines between verbatim markers, including them
+	MD_TOKEN_TABLE_ROW    = 7 // lines starting with "|"
+)
+
+// Anything between these markers is kept as it is and never parsed
+const (
+	VerbatimStartMarker = "<!-- syncdbdocs:verbatim -->"
+	VerbatimEndMarker   = "<!-- /syncdbdocs:verbatim -->"
+)
+
+type MarkdownTokenKind int
+
+var markdownHeadingRe = regexp.MustCompile(`^(#{1,6})(?:\s+(.*?))??(?:\s+#+)?\s*$`)
+
+// -----------------------------------------------------------------------------
+// MarkdownLineToken
+//
+// Each line of a text/markdown document is classified in one of the kinds
+// above, so headings or lists inside code blocks are never taken as structure.
+// Indented code blocks depend on the item they belong to, so the parser tells
+// them apart (see isIndentedCode).
+// -----------------------------------------------------------------------------
+type MarkdownLineToken struct {
+	Kind   MarkdownTokenKind
+	Line   int    // line number, starting at 1
+	Indent int    // number of leading spaces
+	Level  int    // heading level
+	Text   string // trimmed line, or heading text without the #'s
+	Raw    string // line as it was read
+}
+
+// -----------------------------------------------------------------------------
+// TokenizeMarkdownLines
+//
+// Split given text in line tokens. A diagnostic is returned if a code block or
+// a verbatim block is never closed.
+// -----------------------------------------------------------------------------
+func TokenizeMarkdownLines(text string) ([]MarkdownLineToken, []DbLayoutDiagnostic, error) {
+	tokens := []MarkdownLineToken{}
+	diagnostics := []DbLayoutDiagnostic{}
+
+	lines := []string{}
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	// front matter is only taken into account if it is closed
+	frontMatterEnd := -1
+	if len(lines) > 0 && strings.TrimSpace(lines[0]) == "---" {
+		for i := 1; i < len(lines); i++ {
+			if trimmed := strings.TrimSpace(lines[i]); trimmed == "---" || trimmed == "..." {
+				frontMatterEnd = i
+				break
+			}
+		}
+	}
+
+	fence := ""
+	fenceToken := MarkdownLineToken{}
+	inVerbatim := false
+	verbatimToken := MarkdownLineToken{}
+
+	for i, raw := range lines {
+		trimmed := strings.TrimSpace(raw)
+		lineNumber := i + 1
+
+		token := MarkdownLineToken{
+			Kind:   MD_TOKEN_TEXT,
+			Line:   lineNumber,
+			Indent: lineIndent(raw),
+			Level:  0,
+			Text:   trimmed,
+			Raw:    raw,
+		}
+
+		switch {
+		case i <= frontMatterEnd:
+			token.Kind = MD_TOKEN_FRONT_MATTER
+
+		case inVerbatim:
+			token.Kind = MD_TOKEN_VERBATIM
+			if trimmed == VerbatimEndMarker {
+				inVerbatim = false
+			}
+
+		case fence == "" && trimmed == VerbatimStartMarker:
+			token.Kind = MD_TOKEN_VERBATIM
+			inVerbatim = true
+			verbatimToken = token
+
+		case fence != "":
+			token.Kind = MD_TOKEN_CODE
+			if isCommentFence(trimmed) == fence {
+				fence = ""
+			}
+
+		case trimmed == "":
+			token.Kind = MD_TOKEN_BLANK
+
+		case isCommentFence(trimmed) != "":
+			token.Kind = MD_TOKEN_CODE
+			fence = isCommentFence(trimmed)
+			fenceToken = token
+
+		case markdownHeadingRe.MatchString(trimmed):
+			m := markdownHeadingRe.FindStringSubmatch(trimmed)
+			token.Kind = MD_TOKEN_HEADING
+			token.Level = len(m[1])
+			token.Text = strings.TrimSpace(m[2])
+
+		case strings.HasPrefix(trimmed, "-"):
+			token.Kind = MD_TOKEN_LIST
+
+		case strings.HasPrefix(trimmed, "|"):
+			token.Kind = MD_TOKEN_TABLE_ROW
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	if fence != "" {
+		diagnostics = append(diagnostics, DbLayoutDiagnostic{
+			Line:    fenceToken.Line,
+			Column:  fenceToken.Indent + 1,
+			Message: "code block is never closed",
+		})
+	}
+
+	if inVerbatim {
+		diagnostics = append(diagnostics, DbLayoutDiagnostic{
+			Line:    verbatimToken.Line,
+			Column:  verbatimToken.Indent + 1,
+			Message: "verbatim block is never closed",
+		})
+	}
+
+	return tokens, diagnostics, nil
+}
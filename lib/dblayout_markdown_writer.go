@@ -2,9 +2,7 @@
 package lib
 
 import (
-	"fmt"
 	"io"
-	"strconv"
 	"strings"
 )
 
@@ -22,21 +20,51 @@ func IdentityEscape(text string) string {
 	return text
 }
 
+// -----------------------------------------------------------------------------
+// TextEscape
+//
+// Text files have no escapes, except for a backslash before a leading "-", so
+// comments starting with one are never read as a field
+// -----------------------------------------------------------------------------
+func TextEscape(text string) string {
+	if textLeadingDashRe.MatchString(text) {
+		return "\\" + text
+	}
+	return text
+}
+
 // -----------------------------------------------------------------------------
 // MarkdownEscape
 //
-// Escape markdown characters
+// Escape markdown characters. Complete code spans are left untouched.
 // -----------------------------------------------------------------------------
 func MarkdownEscape(text string) string {
 	buff := strings.Builder{}
 	buff.Grow(int(float32(len(text)) * 1.1))
 
-	for _, r := range text {
-		switch r {
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+
+		if c == '`' {
+			run := 1
+			for i+run < len(text) && text[i+run] == '`' {
+				run++
+			}
+
+			closing := indexBacktickRun(text[i+run:], run)
+			if closing >= 0 {
+				end := i + run + closing + run
+				buff.WriteString(text[i:end])
+				i = end - 1
+				continue
+			}
+		}
+
+		switch c {
 		case '\\', '`', '{', '}', '[', ']', '<', '>', '(', ')', '#', '*', '+', '-', '_', '!', '|':
-			buff.WriteRune('\\')
+			buff.WriteByte('\\')
 		}
-		buff.WriteRune(r)
+		buff.WriteByte(c)
 	}
 
 	return buff.String()
@@ -47,8 +75,18 @@ func MarkdownEscape(text string) string {
 //
 // Print markdown document with all the information
 // -----------------------------------------------------------------------------
-func (dbLayout *DbLayout) PrintMarkdown(out io.Writer, lineLength int) {
-	dbLayout.printTextWithEscapeFunction(out, lineLength, MarkdownEscape)
+func (dbLayout *DbLayout) PrintMarkdown(out io.Writer, lineLength int) error {
+	return dbLayout.printBuiltinTemplate(out, lineLength, markdownLayoutTemplate+markdownFieldListTemplate)
+}
+
+// -----------------------------------------------------------------------------
+// PrintMarkdownTable
+//
+// Same as PrintMarkdown but the fields of each table are printed as a table
+// (Name | Type | Nullable | PK | Default | Description) instead of a list.
+// -----------------------------------------------------------------------------
+func (dbLayout *DbLayout) PrintMarkdownTable(out io.Writer, lineLength int) error {
+	return dbLayout.printBuiltinTemplate(out, lineLength, markdownLayoutTemplate+markdownFieldTableTemplate)
 }
 
 // -----------------------------------------------------------------------------
@@ -57,77 +95,38 @@ func (dbLayout *DbLayout) PrintMarkdown(out io.Writer, lineLength int) {
 // Print a text document, which will be similar to markdown but without escaping
 // anything at all.
 // -----------------------------------------------------------------------------
-func (dbLayout *DbLayout) PrintText(out io.Writer, lineLength int) {
-	dbLayout.printTextWithEscapeFunction(out, lineLength, IdentityEscape)
+func (dbLayout *DbLayout) PrintText(out io.Writer, lineLength int) error {
+	return dbLayout.printBuiltinTemplate(out, lineLength, textTemplate)
 }
 
 // -----------------------------------------------------------------------------
-// printTextWithEscapeFunction
-//
-// Helper function that will print markdown or text representation. The only
-// difference is whether text needs to be escaped or not
-// -----------------------------------------------------------------------------
-func (dbLayout *DbLayout) printTextWithEscapeFunction(
-	out io.Writer,
-	lineLength int,
-	escape EscapeFunc,
-) {
-	ww := NewWordWrap(lineLength, 0)
-	wwFields := NewWordWrap(lineLength, 2)
-
-	fmt.Fprintln(out, "# "+escape(dbLayout.Name)+" ("+dbLayout.Type+")")
-	fmt.Fprintln(out)
-	if len(dbLayout.Comment) > 0 {
-		comment := escape(dbLayout.Comment)
-		fmt.Fprintln(out, ww.Wrap(comment))
-		fmt.Fprintln(out)
+// yesNo
+// -----------------------------------------------------------------------------
+func yesNo(value bool) string {
+	if value {
+		return "yes"
 	}
+	return "no"
+}
 
-	// TODO: skip schema if database does not support it
-
-	// NOTE: names won't be escaped because the logic to escape them is
-	//       triky and most of the times will render just fine. Markdown
-	//       readability comes first.
-
-	for _, schemaLayout := range dbLayout.Schemas {
-		if schemaLayout.Name != NoDbSchemaLayoutName {
-			fmt.Fprintln(out, "## "+escape(schemaLayout.Name))
-			fmt.Fprintln(out)
-			if len(schemaLayout.Comment) > 0 {
-				comment := escape(schemaLayout.Comment)
-				fmt.Fprintln(out, ww.Wrap(comment))
-				fmt.Fprintln(out)
-			}
-		}
-
-		for _, tableLayout := range schemaLayout.Tables {
-			fmt.Fprintln(out, "### "+escape(tableLayout.Name))
-			fmt.Fprintln(out)
-			if len(tableLayout.Comment) > 0 {
-				comment := escape(tableLayout.Comment)
-				fmt.Fprintln(out, ww.Wrap(comment))
-				fmt.Fprintln(out)
-			}
+// -----------------------------------------------------------------------------
+// markdownTableCell
+//
+// Table cells must fit in a single line, so line breaks are written as <br>.
+// Pipes are always escaped, even inside code, otherwise they would split the
+// cell.
+// -----------------------------------------------------------------------------
+func markdownTableCell(text string, escape EscapeFunc) string {
+	text = escapeComment(text, escape)
 
-			for _, field := range tableLayout.Fields {
-				typeString := field.Type
-				if field.Length > 0 {
-					typeString += strconv.Itoa(int(field.Length))
-				}
-
-				if field.IsNullable {
-					typeString += "?"
-				}
-
-				// TODO: add extra items with slashes so it is easy to parse
-				fmt.Fprintf(out, "- %s [%s]\n", escape(field.Name), escape(typeString))
-				if len(field.Comment) > 0 {
-					fmt.Fprintln(out)
-					comment := escape(field.Comment)
-					fmt.Fprintln(out, wwFields.Wrap(comment))
-				}
-				fmt.Fprintln(out)
-			}
+	buff := strings.Builder{}
+	buff.Grow(len(text))
+	for i := 0; i < len(text); i++ {
+		if text[i] == '|' && (i == 0 || text[i-1] != '\\') {
+			buff.WriteByte('\\')
 		}
+		buff.WriteByte(text[i])
 	}
+
+	return strings.ReplaceAll(buff.String(), "\n", "<br>")
 }
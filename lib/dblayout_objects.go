@@ -0,0 +1,332 @@
+// Copyright (C) 2021 Pau Sanchez
+package lib
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Sequences, triggers and row level security policies are written like
+// fields, but starting with their kind, and with their definition instead of
+// a type. Sequences go before the tables of their schema, whereas triggers and
+// policies go after the fields of their table:
+//
+//   - sequence order_id_seq [bigint, increment 1, owned by order.id]
+//   - trigger order_audit [AFTER INSERT OR UPDATE FOR EACH ROW EXECUTE audit()]
+//   - policy order_tenant [ALL TO app USING (tenant = current_user)]
+//
+// Definitions always come from the database, and comments follow the same
+// rules as the comments of fields.
+
+const (
+	OBJECT_KIND_SEQUENCE = "sequence"
+	OBJECT_KIND_TRIGGER  = "trigger"
+	OBJECT_KIND_POLICY   = "policy"
+)
+
+var objectLineRe = regexp.MustCompile(`^\-\s+(sequence|trigger|policy)\s+([^\s\[]+)\s*\[(.*)\]\s*$`)
+var triggerDefinitionRe = regexp.MustCompile(`^(BEFORE|AFTER|INSTEAD OF)\s+(.+?)(?:\s+FOR EACH (ROW|STATEMENT))?(?:\s+EXECUTE\s+(.+))?$`)
+var policyDefinitionRe = regexp.MustCompile(`^(?:(RESTRICTIVE)\s+)?(\S+)(?:\s+TO\s+(.+?))?(?:\s+USING\s+(.+?))?(?:\s+WITH CHECK\s+(.+?))?$`)
+
+// -----------------------------------------------------------------------------
+// singleLine
+//
+// Definitions are written in a single line, so whitespace is collapsed
+// -----------------------------------------------------------------------------
+func singleLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// -----------------------------------------------------------------------------
+// Definition
+//
+// Returns the definition as written on files: bigint, increment 1, owned by
+// order.id
+// -----------------------------------------------------------------------------
+func (sequence *DbSequenceLayout) Definition() string {
+	parts := []string{}
+	if sequence.Type != "" {
+		parts = append(parts, sequence.Type)
+	}
+	if sequence.Increment != 0 {
+		parts = append(parts, fmt.Sprintf("increment %d", sequence.Increment))
+	}
+	if sequence.OwnedBy != "" {
+		parts = append(parts, "owned by "+sequence.OwnedBy)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// -----------------------------------------------------------------------------
+// ParseDefinition
+//
+// Inverse of Definition
+// -----------------------------------------------------------------------------
+func (sequence *DbSequenceLayout) ParseDefinition(definition string) error {
+	for _, part := range strings.Split(definition, ",") {
+		part = strings.TrimSpace(part)
+
+		switch {
+		case part == "":
+			continue
+
+		case strings.HasPrefix(part, "increment "):
+			increment, err := strconv.ParseInt(strings.TrimPrefix(part, "increment "), 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid sequence increment: %s", part)
+			}
+			sequence.Increment = increment
+
+		case strings.HasPrefix(part, "owned by "):
+			sequence.OwnedBy = strings.TrimPrefix(part, "owned by ")
+
+		default:
+			sequence.Type = part
+		}
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Definition
+//
+// Returns the definition as written on files: AFTER INSERT OR UPDATE FOR EACH
+// ROW EXECUTE audit()
+// -----------------------------------------------------------------------------
+func (trigger *DbTriggerLayout) Definition() string {
+	definition := trigger.Timing + " " + strings.Join(trigger.Events, " OR ")
+	if trigger.Level != "" {
+		definition += " FOR EACH " + trigger.Level
+	}
+	if trigger.Function != "" {
+		definition += " EXECUTE " + trigger.Function
+	}
+	return strings.TrimSpace(definition)
+}
+
+// -----------------------------------------------------------------------------
+// ParseDefinition
+//
+// Inverse of Definition
+// -----------------------------------------------------------------------------
+func (trigger *DbTriggerLayout) ParseDefinition(definition string) error {
+	if definition == "" {
+		return nil
+	}
+
+	m := triggerDefinitionRe.FindStringSubmatch(definition)
+	if m == nil {
+		return fmt.Errorf("invalid trigger definition: %s", definition)
+	}
+
+	trigger.Timing = m[1]
+	trigger.Events = strings.Split(m[2], " OR ")
+	trigger.Level = m[3]
+	trigger.Function = m[4]
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Definition
+//
+// Returns the definition as written on files: RESTRICTIVE SELECT TO app USING
+// (tenant = current_user) WITH CHECK (...)
+// -----------------------------------------------------------------------------
+func (policy *DbPolicyLayout) Definition() string {
+	parts := []string{}
+	if policy.IsRestrictive {
+		parts = append(parts, "RESTRICTIVE")
+	}
+	parts = append(parts, policy.Command)
+	if len(policy.Roles) > 0 {
+		parts = append(parts, "TO "+strings.Join(policy.Roles, ", "))
+	}
+	if policy.Using != "" {
+		parts = append(parts, "USING "+policy.Using)
+	}
+	if policy.Check != "" {
+		parts = append(parts, "WITH CHECK "+policy.Check)
+	}
+	return strings.TrimSpace(strings.Join(parts, " "))
+}
+
+// -----------------------------------------------------------------------------
+// ParseDefinition
+//
+// Inverse of Definition
+// -----------------------------------------------------------------------------
+func (policy *DbPolicyLayout) ParseDefinition(definition string) error {
+	if definition == "" {
+		return nil
+	}
+
+	m := policyDefinitionRe.FindStringSubmatch(definition)
+	if m == nil {
+		return fmt.Errorf("invalid policy definition: %s", definition)
+	}
+
+	policy.IsRestrictive = m[1] != ""
+	policy.Command = m[2]
+	if m[3] != "" {
+		policy.Roles = strings.Split(m[3], ", ")
+	}
+	policy.Using = m[4]
+	policy.Check = m[5]
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// mergeSequences
+//
+// Merge sequences preserving the order of the current ones, the same way
+// fields are merged
+// -----------------------------------------------------------------------------
+func mergeSequences(
+	sequences []*DbSequenceLayout,
+	otherSequences []*DbSequenceLayout,
+	preserveComments bool,
+	preserveMissing bool,
+) []*DbSequenceLayout {
+	lookup := make(map[string]*DbSequenceLayout, len(sequences))
+	for _, sequencePtr := range sequences {
+		lookup[sequencePtr.Name] = sequencePtr
+	}
+
+	otherLookup := make(map[string]*DbSequenceLayout, len(otherSequences))
+	for _, otherSequencePtr := range otherSequences {
+		otherLookup[otherSequencePtr.Name] = otherSequencePtr
+	}
+
+	merged := []*DbSequenceLayout{}
+	deleted := []*DbSequenceLayout{}
+
+	for _, sequencePtr := range sequences {
+		if otherSequencePtr, ok := otherLookup[sequencePtr.Name]; ok {
+			merged = append(merged, otherSequencePtr)
+
+			if preserveComments || otherSequencePtr.Comment == "" {
+				otherSequencePtr.Comment = sequencePtr.Comment
+			}
+			if otherSequencePtr.Verbatim == "" {
+				otherSequencePtr.Verbatim = sequencePtr.Verbatim
+			}
+		} else if preserveMissing {
+			dupSequence := *sequencePtr
+			dupSequence.Name = addDeletedPrefix(dupSequence.Name)
+			deleted = append(deleted, &dupSequence)
+		}
+	}
+
+	for _, otherSequencePtr := range otherSequences {
+		if _, ok := lookup[otherSequencePtr.Name]; !ok {
+			merged = append(merged, otherSequencePtr)
+		}
+	}
+
+	return append(merged, deleted...)
+}
+
+// -----------------------------------------------------------------------------
+// mergeTriggers
+//
+// Merge triggers preserving the order of the current ones, the same way
+// fields are merged
+// -----------------------------------------------------------------------------
+func mergeTriggers(
+	triggers []*DbTriggerLayout,
+	otherTriggers []*DbTriggerLayout,
+	preserveComments bool,
+	preserveMissing bool,
+) []*DbTriggerLayout {
+	lookup := make(map[string]*DbTriggerLayout, len(triggers))
+	for _, triggerPtr := range triggers {
+		lookup[triggerPtr.Name] = triggerPtr
+	}
+
+	otherLookup := make(map[string]*DbTriggerLayout, len(otherTriggers))
+	for _, otherTriggerPtr := range otherTriggers {
+		otherLookup[otherTriggerPtr.Name] = otherTriggerPtr
+	}
+
+	merged := []*DbTriggerLayout{}
+	deleted := []*DbTriggerLayout{}
+
+	for _, triggerPtr := range triggers {
+		if otherTriggerPtr, ok := otherLookup[triggerPtr.Name]; ok {
+			merged = append(merged, otherTriggerPtr)
+
+			if preserveComments || otherTriggerPtr.Comment == "" {
+				otherTriggerPtr.Comment = triggerPtr.Comment
+			}
+			if otherTriggerPtr.Verbatim == "" {
+				otherTriggerPtr.Verbatim = triggerPtr.Verbatim
+			}
+		} else if preserveMissing {
+			dupTrigger := *triggerPtr
+			dupTrigger.Name = addDeletedPrefix(dupTrigger.Name)
+			deleted = append(deleted, &dupTrigger)
+		}
+	}
+
+	for _, otherTriggerPtr := range otherTriggers {
+		if _, ok := lookup[otherTriggerPtr.Name]; !ok {
+			merged = append(merged, otherTriggerPtr)
+		}
+	}
+
+	return append(merged, deleted...)
+}
+
+// -----------------------------------------------------------------------------
+// mergePolicies
+//
+// Merge policies preserving the order of the current ones, the same way
+// fields are merged
+// -----------------------------------------------------------------------------
+func mergePolicies(
+	policies []*DbPolicyLayout,
+	otherPolicies []*DbPolicyLayout,
+	preserveComments bool,
+	preserveMissing bool,
+) []*DbPolicyLayout {
+	lookup := make(map[string]*DbPolicyLayout, len(policies))
+	for _, policyPtr := range policies {
+		lookup[policyPtr.Name] = policyPtr
+	}
+
+	otherLookup := make(map[string]*DbPolicyLayout, len(otherPolicies))
+	for _, otherPolicyPtr := range otherPolicies {
+		otherLookup[otherPolicyPtr.Name] = otherPolicyPtr
+	}
+
+	merged := []*DbPolicyLayout{}
+	deleted := []*DbPolicyLayout{}
+
+	for _, policyPtr := range policies {
+		if otherPolicyPtr, ok := otherLookup[policyPtr.Name]; ok {
+			merged = append(merged, otherPolicyPtr)
+
+			if preserveComments || otherPolicyPtr.Comment == "" {
+				otherPolicyPtr.Comment = policyPtr.Comment
+			}
+			if otherPolicyPtr.Verbatim == "" {
+				otherPolicyPtr.Verbatim = policyPtr.Verbatim
+			}
+		} else if preserveMissing {
+			dupPolicy := *policyPtr
+			dupPolicy.Name = addDeletedPrefix(dupPolicy.Name)
+			deleted = append(deleted, &dupPolicy)
+		}
+	}
+
+	for _, otherPolicyPtr := range otherPolicies {
+		if _, ok := lookup[otherPolicyPtr.Name]; !ok {
+			merged = append(merged, otherPolicyPtr)
+		}
+	}
+
+	return append(merged, deleted...)
+}
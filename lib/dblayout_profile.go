@@ -0,0 +1,231 @@
+// Copyright (C) 2021 Pau Sanchez
+package lib
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Profiles describe the values of a field, as found on a sample of rows of
+// its table (-profile). They are written right after the comment of the
+// field, in a single line, so they can be read back:
+//
+//   - status [varchar16]
+//
+//     Status of the order
+//
+//     > 1000 rows sampled: 3 distinct, 0.0% null, min `CANCELLED`, max `SHIPPED`, top `SHIPPED` (870), `NEW` (128), `CANCELLED` (2)
+//
+// Values are written as code spans, so they are never escaped nor taken as
+// markdown. Long values are truncated.
+
+const profileMaxValueLength = 32
+
+var profileLineRe = regexp.MustCompile(`^> [0-9]+ rows? sampled:`)
+
+// -----------------------------------------------------------------------------
+// DbValueCount
+// -----------------------------------------------------------------------------
+type DbValueCount struct {
+	Value string
+	Count int64
+}
+
+// -----------------------------------------------------------------------------
+// DbFieldProfile
+// -----------------------------------------------------------------------------
+type DbFieldProfile struct {
+	Rows     int64 // sampled rows
+	Nulls    int64
+	Distinct int64 // distinct values (other than NULL) on the sampled rows
+	Min      string
+	Max      string
+	Top      []DbValueCount // most repeated values, empty when all are unique
+}
+
+// -----------------------------------------------------------------------------
+// NewDbFieldProfile
+//
+// Profile of given sampled values (nil for NULL), keeping up to top most
+// repeated values. Min and max are compared as numbers when all values are
+// numbers.
+// -----------------------------------------------------------------------------
+func NewDbFieldProfile(values []*string, top int) DbFieldProfile {
+	profile := DbFieldProfile{
+		Rows:     int64(len(values)),
+		Nulls:    0,
+		Distinct: 0,
+		Min:      "",
+		Max:      "",
+		Top:      []DbValueCount{},
+	}
+
+	counts := map[string]int64{}
+	numeric := true
+	for _, value := range values {
+		if value == nil {
+			profile.Nulls++
+			continue
+		}
+
+		counts[*value]++
+		if _, err := strconv.ParseFloat(*value, 64); err != nil {
+			numeric = false
+		}
+	}
+
+	if len(counts) == 0 {
+		return profile
+	}
+
+	distinct := make([]DbValueCount, 0, len(counts))
+	for value, count := range counts {
+		distinct = append(distinct, DbValueCount{Value: value, Count: count})
+	}
+
+	less := func(a string, b string) bool {
+		if numeric {
+			x, _ := strconv.ParseFloat(a, 64)
+			y, _ := strconv.ParseFloat(b, 64)
+			return x < y
+		}
+		return a < b
+	}
+
+	profile.Distinct = int64(len(distinct))
+	profile.Min = distinct[0].Value
+	profile.Max = distinct[0].Value
+	for _, item := range distinct {
+		if less(item.Value, profile.Min) {
+			profile.Min = item.Value
+		}
+		if less(profile.Max, item.Value) {
+			profile.Max = item.Value
+		}
+	}
+
+	// top values say nothing when all values are unique (e.g. ids)
+	if profile.Distinct == profile.Rows-profile.Nulls {
+		return profile
+	}
+
+	sort.Slice(distinct, func(i, j int) bool {
+		if distinct[i].Count != distinct[j].Count {
+			return distinct[i].Count > distinct[j].Count
+		}
+		return less(distinct[i].Value, distinct[j].Value)
+	})
+
+	if len(distinct) > top {
+		distinct = distinct[:top]
+	}
+	profile.Top = distinct
+
+	return profile
+}
+
+// -----------------------------------------------------------------------------
+// profileValue
+//
+// Value as a code span in a single line, truncated when too long
+// -----------------------------------------------------------------------------
+func profileValue(value string) string {
+	if !utf8.ValidString(value) {
+		return "`<binary>`"
+	}
+
+	value = strings.Join(strings.Fields(strings.ReplaceAll(value, "`", "'")), " ")
+	if utf8.RuneCountInString(value) > profileMaxValueLength {
+		value = string([]rune(value)[:profileMaxValueLength]) + "..."
+	}
+	if value == "" {
+		value = "''"
+	}
+
+	return "`" + value + "`"
+}
+
+// -----------------------------------------------------------------------------
+// String
+//
+// Returns the profile as written on files (without the leading "> "), e.g.
+// 1000 rows sampled: 3 distinct, 0.0% null, min `A`, max `C`, top `B` (870)
+// -----------------------------------------------------------------------------
+func (profile *DbFieldProfile) String() string {
+	nulls := 0.0
+	if profile.Rows > 0 {
+		nulls = 100 * float64(profile.Nulls) / float64(profile.Rows)
+	}
+
+	parts := []string{
+		fmt.Sprintf("%d distinct", profile.Distinct),
+		fmt.Sprintf("%.1f%% null", nulls),
+	}
+
+	if profile.Distinct > 0 {
+		parts = append(parts, "min "+profileValue(profile.Min), "max "+profileValue(profile.Max))
+	}
+
+	if len(profile.Top) > 0 {
+		top := make([]string, 0, len(profile.Top))
+		for _, item := range profile.Top {
+			top = append(top, fmt.Sprintf("%s (%d)", profileValue(item.Value), item.Count))
+		}
+		parts = append(parts, "top "+strings.Join(top, ", "))
+	}
+
+	rows := "rows"
+	if profile.Rows == 1 {
+		rows = "row"
+	}
+
+	return fmt.Sprintf("%d %s sampled: %s", profile.Rows, rows, strings.Join(parts, ", "))
+}
+
+// -----------------------------------------------------------------------------
+// commentWithProfile
+//
+// Profiles are written as the last paragraph of the comment where there is no
+// other place for them (e.g. tables of fields)
+// -----------------------------------------------------------------------------
+func commentWithProfile(comment string, profile string) string {
+	if profile == "" {
+		return comment
+	}
+	if comment == "" {
+		return "> " + profile
+	}
+	return comment + "\n\n> " + profile
+}
+
+// -----------------------------------------------------------------------------
+// ExtractProfiles
+//
+// Move profiles found on the last paragraph of the comment of each field to
+// its profile
+// -----------------------------------------------------------------------------
+func (dbLayout *DbLayout) ExtractProfiles() {
+	for _, schemaLayout := range dbLayout.Schemas {
+		for _, tableLayout := range schemaLayout.Tables {
+			for _, field := range tableLayout.Fields {
+				comment := ""
+				paragraph := field.Comment
+				if index := strings.LastIndex(field.Comment, "\n\n"); index >= 0 {
+					comment = field.Comment[:index]
+					paragraph = field.Comment[index+2:]
+				}
+
+				if strings.Contains(paragraph, "\n") || !profileLineRe.MatchString(paragraph) {
+					continue
+				}
+
+				field.Profile = strings.TrimPrefix(paragraph, "> ")
+				field.Comment = comment
+			}
+		}
+	}
+}
@@ -0,0 +1,434 @@
+// Copyright (C) 2021 Pau Sanchez
+package lib
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Documentation can be split in several files, every file being a complete
+// document on its own (starting with the database heading), so each of them
+// can be read with the same parser:
+//
+//  - by schema: index.md + schema.md
+//  - by table:  index.md (with schema comments) + schema/table.md
+const (
+	SPLIT_BY_SCHEMA = "schema"
+	SPLIT_BY_TABLE  = "table"
+)
+
+const SplitIndexName = "index"
+
+var splitPathUnsafeRe = regexp.MustCompile(`[^A-Za-z0-9_.\-]+`)
+
+// -----------------------------------------------------------------------------
+// DbLayoutFile
+//
+// Part of a layout that should be written to given path, relative to the
+// output directory.
+// -----------------------------------------------------------------------------
+type DbLayoutFile struct {
+	Path   string
+	Layout *DbLayout
+}
+
+// -----------------------------------------------------------------------------
+// splitPathName
+//
+// Converts given schema or table name into a safe file name. Items marked as
+// deleted are kept in the same file they were.
+// -----------------------------------------------------------------------------
+func splitPathName(name string) string {
+	name = strings.TrimPrefix(name, DeletedPrefix)
+	name = strings.Trim(splitPathUnsafeRe.ReplaceAllString(name, "_"), ".")
+	if name == "" {
+		name = "_"
+	}
+	return name
+}
+
+// -----------------------------------------------------------------------------
+// newPartialLayout
+// -----------------------------------------------------------------------------
+func (dbLayout *DbLayout) newPartialLayout() *DbLayout {
+	partial := NewDbLayout(dbLayout.Name)
+	partial.Type = dbLayout.Type
+	return &partial
+}
+
+// -----------------------------------------------------------------------------
+// SplitFiles
+//
+// Split the layout in several files, depending on splitBy (schema or table).
+// The index file always goes first. Items whose names map to the same path
+// are written together on the same file.
+// -----------------------------------------------------------------------------
+func (dbLayout *DbLayout) SplitFiles(splitBy string, extension string) ([]DbLayoutFile, error) {
+	if splitBy != SPLIT_BY_SCHEMA && splitBy != SPLIT_BY_TABLE {
+		return nil, fmt.Errorf("cannot split by '%s', valid values are: schema | table", splitBy)
+	}
+
+	index := dbLayout.newPartialLayout()
+	index.Comment = dbLayout.Comment
+	index.Verbatim = dbLayout.Verbatim
+	index.FrontMatter = dbLayout.FrontMatter
+
+	files := []DbLayoutFile{{Path: SplitIndexName + extension, Layout: index}}
+	fileLookup := map[string]*DbLayout{files[0].Path: index}
+
+	getFile := func(path string) *DbLayout {
+		if partial, ok := fileLookup[path]; ok {
+			return partial
+		}
+		partial := dbLayout.newPartialLayout()
+		files = append(files, DbLayoutFile{Path: path, Layout: partial})
+		fileLookup[path] = partial
+		return partial
+	}
+
+	for _, schemaLayout := range dbLayout.Schemas {
+		hasSchema := schemaLayout.Name != NoDbSchemaLayoutName
+
+		// schema comments go to the schema file, or the index when splitting
+		// by table
+		schemaFile := index
+		if splitBy == SPLIT_BY_SCHEMA && hasSchema {
+			schemaFile = getFile(splitPathName(schemaLayout.Name) + extension)
+		}
+
+		partialSchema := schemaFile.GetOrCreateSchema(schemaLayout.Name)
+		partialSchema.Comment = schemaLayout.Comment
+		partialSchema.Verbatim = schemaLayout.Verbatim
+		partialSchema.Sequences = schemaLayout.Sequences
+
+		for _, tableLayout := range schemaLayout.Tables {
+			tableFile := schemaFile
+			if splitBy == SPLIT_BY_TABLE {
+				path := splitPathName(tableLayout.Name) + extension
+				if hasSchema {
+					path = filepath.Join(splitPathName(schemaLayout.Name), path)
+				}
+				tableFile = getFile(path)
+			}
+
+			tableSchema := tableFile.GetOrCreateSchema(schemaLayout.Name)
+			tableSchema.Tables = append(tableSchema.Tables, tableLayout)
+		}
+	}
+
+	for _, file := range files {
+		file.Layout.RebuildLookups()
+	}
+
+	return files, nil
+}
+
+// -----------------------------------------------------------------------------
+// DbLayoutParsedFile
+//
+// File of a split layout, as read from the output directory
+// -----------------------------------------------------------------------------
+type DbLayoutParsedFile struct {
+	Path        string
+	Layout      *DbLayout
+	Diagnostics []DbLayoutDiagnostic
+}
+
+// -----------------------------------------------------------------------------
+// isSplitFile
+//
+// Whether SplitFiles could have written given layout to given path, relative
+// to the output directory: the index, or a file with a schema or table whose
+// name maps to its path. Tables marked as deleted keep their path.
+// -----------------------------------------------------------------------------
+func isSplitFile(path string, extension string, layout *DbLayout) bool {
+	path = filepath.ToSlash(path)
+	if path == SplitIndexName+extension {
+		return true
+	}
+
+	for _, schemaLayout := range layout.Schemas {
+		schemaPath := ""
+		if schemaLayout.Name != NoDbSchemaLayoutName {
+			schemaPath = splitPathName(schemaLayout.Name) + "/"
+			if path == splitPathName(schemaLayout.Name)+extension {
+				return true
+			}
+		}
+
+		for _, tableLayout := range schemaLayout.Tables {
+			if path == schemaPath+splitPathName(tableLayout.Name)+extension {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// -----------------------------------------------------------------------------
+// readSplitFiles
+//
+// Reads all files with given extension that are part of a split layout: the
+// ones on the directory and one level below that could have been written by
+// SplitFiles. Any other file (e.g. a README.md) is skipped, so it is never
+// merged nor removed. The index always goes first.
+// -----------------------------------------------------------------------------
+func readSplitFiles(dir string, extension string) ([]DbLayoutParsedFile, error) {
+	paths := []string{}
+	for _, pattern := range []string{"*" + extension, filepath.Join("*", "*"+extension)} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matches...)
+	}
+
+	index := filepath.Join(dir, SplitIndexName+extension)
+	sort.SliceStable(paths, func(i, j int) bool {
+		if paths[i] == index || paths[j] == index {
+			return paths[i] == index && paths[j] != index
+		}
+		return paths[i] < paths[j]
+	})
+
+	files := []DbLayoutParsedFile{}
+	for _, path := range paths {
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil, err
+		}
+
+		layout, diagnostics, err := NewDbLayoutFromParsedFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !isSplitFile(relPath, extension, layout) {
+			continue
+		}
+
+		files = append(files, DbLayoutParsedFile{
+			Path:        path,
+			Layout:      layout,
+			Diagnostics: diagnostics,
+		})
+	}
+
+	return files, nil
+}
+
+// -----------------------------------------------------------------------------
+// WriteSplitFiles
+//
+// Write the layout split in several files inside the given directory using
+// the given print function. Each file is written with WriteFileAtomic (and
+// backup). When clean is set, any other documentation file (e.g. from a table
+// that has been dropped) is removed, but never files that are not part of the
+// documentation (see readSplitFiles).
+// -----------------------------------------------------------------------------
+func (dbLayout *DbLayout) WriteSplitFiles(
+	dir string,
+	splitBy string,
+	extension string,
+	clean bool,
+	backup bool,
+	print func(*DbLayout, io.Writer) error,
+) error {
+	files, err := dbLayout.SplitFiles(splitBy, extension)
+	if err != nil {
+		return err
+	}
+
+	written := map[string]bool{}
+	for _, file := range files {
+		path := filepath.Join(dir, file.Path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+
+		layout := file.Layout
+		err := WriteFileAtomic(path, backup, func(out io.Writer) error {
+			return print(layout, out)
+		})
+		if err != nil {
+			return err
+		}
+
+		written[path] = true
+	}
+
+	if !clean {
+		return nil
+	}
+
+	existing, err := readSplitFiles(dir, extension)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range existing {
+		path := file.Path
+		if written[path] {
+			continue
+		}
+
+		// with backup, removed files are kept as backups as well
+		if backup {
+			err = os.Rename(path, path+BackupSuffix)
+		} else {
+			err = os.Remove(path)
+		}
+		if err != nil {
+			return err
+		}
+
+		// remove schema directories that become empty, ignoring errors
+		if parent := filepath.Dir(path); parent != filepath.Clean(dir) {
+			if entries, _ := ioutil.ReadDir(parent); len(entries) == 0 {
+				os.Remove(parent)
+			}
+		}
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// DiffSplitFiles
+//
+// Same as WriteSplitFiles but nothing is written: returns the unified diff of
+// all files that would change (or be removed, when clean is set) instead.
+// -----------------------------------------------------------------------------
+func (dbLayout *DbLayout) DiffSplitFiles(
+	dir string,
+	splitBy string,
+	extension string,
+	clean bool,
+	print func(*DbLayout, io.Writer) error,
+) (string, error) {
+	files, err := dbLayout.SplitFiles(splitBy, extension)
+	if err != nil {
+		return "", err
+	}
+
+	var diff strings.Builder
+
+	written := map[string]bool{}
+	for _, file := range files {
+		path := filepath.Join(dir, file.Path)
+		written[path] = true
+
+		var contents bytes.Buffer
+		if err := print(file.Layout, &contents); err != nil {
+			return "", err
+		}
+
+		existing, err := ioutil.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+
+		diff.WriteString(UnifiedDiff(path, path, string(existing), contents.String()))
+	}
+
+	if !clean {
+		return diff.String(), nil
+	}
+
+	existing, err := readSplitFiles(dir, extension)
+	if err != nil {
+		return "", err
+	}
+
+	for _, file := range existing {
+		path := file.Path
+		if written[path] {
+			continue
+		}
+
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+
+		diff.WriteString(UnifiedDiff(path, path, string(contents), ""))
+	}
+
+	return diff.String(), nil
+}
+
+// -----------------------------------------------------------------------------
+// NewDbLayoutFromParsedDir
+//
+// Reassemble a layout from a directory written with WriteSplitFiles. The
+// index file sets the extension of the rest of the files, and the database
+// comments. Schemas and tables are appended in the order they are read.
+// -----------------------------------------------------------------------------
+func NewDbLayoutFromParsedDir(dir string) (*DbLayout, []DbLayoutDiagnostic, error) {
+	extension := ""
+	for _, candidate := range []string{".md", ".markdown", ".txt"} {
+		if _, err := os.Stat(filepath.Join(dir, SplitIndexName+candidate)); err == nil {
+			extension = candidate
+			break
+		}
+	}
+
+	layout := NewDbLayout("")
+	diagnostics := []DbLayoutDiagnostic{}
+
+	// an empty directory is just an empty layout
+	if extension == "" {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(entries) > 0 {
+			return nil, nil, errors.New("cannot find index file on " + dir)
+		}
+		return &layout, diagnostics, nil
+	}
+
+	files, err := readSplitFiles(dir, extension)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i, file := range files {
+		partial := file.Layout
+		diagnostics = append(diagnostics, file.Diagnostics...)
+
+		// database information only comes from the index
+		if i == 0 {
+			layout.Name = partial.Name
+			layout.Type = partial.Type
+			layout.Comment = partial.Comment
+			layout.Verbatim = partial.Verbatim
+			layout.FrontMatter = partial.FrontMatter
+		}
+
+		for _, partialSchema := range partial.Schemas {
+			schemaLayout := layout.GetOrCreateSchema(partialSchema.Name)
+			if schemaLayout.Comment == "" {
+				schemaLayout.Comment = partialSchema.Comment
+			}
+			if schemaLayout.Verbatim == "" {
+				schemaLayout.Verbatim = partialSchema.Verbatim
+			}
+			schemaLayout.Sequences = append(schemaLayout.Sequences, partialSchema.Sequences...)
+			schemaLayout.Tables = append(schemaLayout.Tables, partialSchema.Tables...)
+		}
+	}
+
+	layout.RebuildLookups()
+
+	return &layout, diagnostics, nil
+}
@@ -0,0 +1,179 @@
+// Copyright (C) 2021 Pau Sanchez
+package lib
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Table statistics are only read from the database when requested
+// (-with-stats), and written as the first line of the table, right after its
+// name, so they can be read back:
+//
+//   ### user
+//
+//   > ~12345 rows, 1.5 MB, analyzed 2021-03-01 10:00:00
+//
+// Rows are estimates on most databases. Statistics are never compared on
+// merges: the ones in the file are kept unless the database has any.
+
+const statsSizeUnits = "KMGTP"
+
+// -----------------------------------------------------------------------------
+// DbTableStats
+// -----------------------------------------------------------------------------
+type DbTableStats struct {
+	Rows         int64  // estimated number of rows, negative when unknown
+	Size         int64  // bytes on disk (including indexes), negative when unknown
+	LastAnalyzed string // empty when unknown
+}
+
+// -----------------------------------------------------------------------------
+// NewDbTableStats
+// -----------------------------------------------------------------------------
+func NewDbTableStats() DbTableStats {
+	return DbTableStats{
+		Rows:         -1,
+		Size:         -1,
+		LastAnalyzed: "",
+	}
+}
+
+// -----------------------------------------------------------------------------
+// IsEmpty
+//
+// True when nothing is known about the table
+// -----------------------------------------------------------------------------
+func (stats *DbTableStats) IsEmpty() bool {
+	return stats.Rows < 0 && stats.Size < 0 && stats.LastAnalyzed == ""
+}
+
+// -----------------------------------------------------------------------------
+// String
+//
+// Returns the statistics as written on files (without the leading "> "), e.g.
+// ~12345 rows, 1.5 MB, analyzed 2021-03-01 10:00:00
+// -----------------------------------------------------------------------------
+func (stats *DbTableStats) String() string {
+	parts := []string{}
+	if stats.Rows >= 0 {
+		parts = append(parts, fmt.Sprintf("~%d rows", stats.Rows))
+	}
+	if stats.Size >= 0 {
+		parts = append(parts, formatStatsSize(stats.Size))
+	}
+	if stats.LastAnalyzed != "" {
+		parts = append(parts, "analyzed "+stats.LastAnalyzed)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// -----------------------------------------------------------------------------
+// formatStatsSize
+//
+// Human readable size, with one decimal on units bigger than bytes
+// -----------------------------------------------------------------------------
+func formatStatsSize(size int64) string {
+	if size < 1024 {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	value := float64(size) / 1024
+	unit := 0
+	for value >= 1024 && unit < len(statsSizeUnits)-1 {
+		value /= 1024
+		unit++
+	}
+
+	return fmt.Sprintf("%.1f %cB", value, statsSizeUnits[unit])
+}
+
+// -----------------------------------------------------------------------------
+// parseStatsSize
+//
+// Inverse of formatStatsSize, e.g. 1.5 MB
+// -----------------------------------------------------------------------------
+func parseStatsSize(text string) (int64, error) {
+	fields := strings.Fields(text)
+	if len(fields) != 2 || !strings.HasSuffix(fields[1], "B") {
+		return 0, fmt.Errorf("invalid size: %s", text)
+	}
+
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, err
+	}
+
+	unit := strings.TrimSuffix(fields[1], "B")
+	if unit != "" {
+		index := strings.Index(statsSizeUnits, unit)
+		if index < 0 {
+			return 0, fmt.Errorf("invalid size unit: %s", text)
+		}
+		for i := 0; i <= index; i++ {
+			value *= 1024
+		}
+	}
+
+	return int64(value + 0.5), nil
+}
+
+// -----------------------------------------------------------------------------
+// parseStatsLine
+//
+// Returns the statistics of given line, or nil when it is not a statistics
+// line
+// -----------------------------------------------------------------------------
+func parseStatsLine(line string) *DbTableStats {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "> ") {
+		return nil
+	}
+
+	stats := NewDbTableStats()
+	for _, part := range strings.Split(strings.TrimSpace(line[1:]), ", ") {
+		switch {
+		case strings.HasPrefix(part, "~") && strings.HasSuffix(part, " rows"):
+			rows, err := strconv.ParseInt(strings.TrimSuffix(part[1:], " rows"), 10, 64)
+			if err != nil {
+				return nil
+			}
+			stats.Rows = rows
+
+		case strings.HasPrefix(part, "analyzed "):
+			stats.LastAnalyzed = strings.TrimPrefix(part, "analyzed ")
+
+		default:
+			size, err := parseStatsSize(part)
+			if err != nil {
+				return nil
+			}
+			stats.Size = size
+		}
+	}
+
+	return &stats
+}
+
+// -----------------------------------------------------------------------------
+// ExtractStats
+//
+// Move statistics found on the first paragraph of the comment of each table
+// to its stats
+// -----------------------------------------------------------------------------
+func (dbLayout *DbLayout) ExtractStats() {
+	for _, schemaLayout := range dbLayout.Schemas {
+		for _, tableLayout := range schemaLayout.Tables {
+			lines := strings.Split(tableLayout.Comment, "\n")
+
+			stats := parseStatsLine(lines[0])
+			if stats == nil || (len(lines) > 1 && strings.TrimSpace(lines[1]) != "") {
+				continue
+			}
+
+			tableLayout.Stats = stats
+			tableLayout.Comment = strings.TrimLeft(strings.Join(lines[1:], "\n"), "\n")
+		}
+	}
+}
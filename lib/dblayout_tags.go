@@ -0,0 +1,335 @@
+// Copyright (C) 2021 Pau Sanchez
+package lib
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Tags classify tables and fields (e.g. @pii or @retention:90d). They can be
+// written on a comment paragraph of their own, or after the type of a field,
+// and they are always moved out of the comment when read. Any other @word
+// (e.g. "ping @oncall") is just part of the comment. Values with spaces are
+// quoted: @classification:"highly confidential", escaping quotes and
+// backslashes inside them: @stored:"\"a\"+1"
+
+var tagRe = regexp.MustCompile(`(^|\s)@([A-Za-z][A-Za-z0-9_\-]*)(?::("(?:[^"\\]|\\.)*"|[^\s,;"]+))?`)
+
+var tagValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+var tagValueUnescaper = strings.NewReplacer(`\\`, `\`, `\"`, `"`)
+
+// -----------------------------------------------------------------------------
+// DbTag
+// -----------------------------------------------------------------------------
+type DbTag struct {
+	Name  string
+	Value string // optional
+}
+
+type DbTags []DbTag
+
+// -----------------------------------------------------------------------------
+// String
+//
+// Returns the tag as written on files: @name, @name:value or @name:"value"
+// -----------------------------------------------------------------------------
+func (tag DbTag) String() string {
+	if tag.Value == "" {
+		return "@" + tag.Name
+	}
+	if strings.ContainsAny(tag.Value, " \t\n,;\"") {
+		return "@" + tag.Name + ":\"" + tagValueEscaper.Replace(tag.Value) + "\""
+	}
+	return "@" + tag.Name + ":" + tag.Value
+}
+
+// -----------------------------------------------------------------------------
+// newDbTagFromMatch
+// -----------------------------------------------------------------------------
+func newDbTagFromMatch(m []string) DbTag {
+	value := m[3]
+	if strings.HasPrefix(value, "\"") {
+		value = tagValueUnescaper.Replace(value[1 : len(value)-1])
+	}
+	return DbTag{Name: m[2], Value: value}
+}
+
+// -----------------------------------------------------------------------------
+// String
+//
+// Returns all tags separated by spaces
+// -----------------------------------------------------------------------------
+func (tags DbTags) String() string {
+	result := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		result = append(result, tag.String())
+	}
+	return strings.Join(result, " ")
+}
+
+// -----------------------------------------------------------------------------
+// Get
+//
+// Returns the tag with given name (case insensitive)
+// -----------------------------------------------------------------------------
+func (tags DbTags) Get(name string) (DbTag, bool) {
+	for _, tag := range tags {
+		if strings.EqualFold(tag.Name, name) {
+			return tag, true
+		}
+	}
+	return DbTag{}, false
+}
+
+// -----------------------------------------------------------------------------
+// Has
+// -----------------------------------------------------------------------------
+func (tags DbTags) Has(name string) bool {
+	_, ok := tags.Get(name)
+	return ok
+}
+
+// -----------------------------------------------------------------------------
+// Add
+//
+// Add given tags in order, replacing the value of existing tags
+// -----------------------------------------------------------------------------
+func (tags DbTags) Add(others ...DbTag) DbTags {
+	tags = append(DbTags{}, tags...)
+	for _, other := range others {
+		replaced := false
+		for i := range tags {
+			if strings.EqualFold(tags[i].Name, other.Name) {
+				tags[i].Value = other.Value
+				replaced = true
+			}
+		}
+		if !replaced {
+			tags = append(tags, other)
+		}
+	}
+	return tags
+}
+
+// -----------------------------------------------------------------------------
+// Remove
+//
+// Returns the tags without the ones with given names (case insensitive)
+// -----------------------------------------------------------------------------
+func (tags DbTags) Remove(names ...string) DbTags {
+	result := DbTags{}
+	for _, tag := range tags {
+		removed := false
+		for _, name := range names {
+			if strings.EqualFold(tag.Name, name) {
+				removed = true
+			}
+		}
+		if !removed {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+// -----------------------------------------------------------------------------
+// Keep
+//
+// Returns only the tags with given names (case insensitive)
+// -----------------------------------------------------------------------------
+func (tags DbTags) Keep(names ...string) DbTags {
+	result := DbTags{}
+	for _, tag := range tags {
+		for _, name := range names {
+			if strings.EqualFold(tag.Name, name) {
+				result = append(result, tag)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// -----------------------------------------------------------------------------
+// mergeTags
+//
+// Tags of an item on a file (tags) merged with the ones read from the database
+// (otherTags). Catalog tags describe the database itself, so they always come
+// from the database, whereas the rest follow the same rules as comments.
+// -----------------------------------------------------------------------------
+func mergeTags(tags DbTags, otherTags DbTags, catalogTags []string, preserveComments bool) DbTags {
+	userTags := tags.Remove(catalogTags...)
+	otherUserTags := otherTags.Remove(catalogTags...)
+	if len(otherUserTags) > 0 && !(preserveComments && len(userTags) > 0) {
+		userTags = otherUserTags
+	}
+
+	return otherTags.Keep(catalogTags...).Add(userTags...)
+}
+
+// -----------------------------------------------------------------------------
+// ParseTags
+//
+// Parse tags from a text like "@pii @retention:90d". Whatever is not a tag is
+// returned as it is, separated by spaces.
+// -----------------------------------------------------------------------------
+func ParseTags(text string) (string, DbTags) {
+	tags := DbTags{}
+	for _, m := range tagRe.FindAllStringSubmatch(text, -1) {
+		tags = tags.Add(newDbTagFromMatch(m))
+	}
+
+	rest := tagRe.ReplaceAllString(text, "$1")
+	return strings.Join(strings.Fields(rest), " "), tags
+}
+
+// -----------------------------------------------------------------------------
+// extractCommentTags
+//
+// Remove the paragraphs of given comment that only have tags. Paragraphs with
+// any other text, lists and code blocks are never taken into account.
+// -----------------------------------------------------------------------------
+func extractCommentTags(comment string) (string, DbTags) {
+	tags := DbTags{}
+	if !tagRe.MatchString(comment) {
+		return comment, tags
+	}
+
+	blocks := []string{}
+
+	for _, block := range splitCommentBlocks(strings.Split(comment, "\n")) {
+		if isVerbatimCommentBlock(block) {
+			blocks = append(blocks, strings.Join(block, "\n"))
+			continue
+		}
+
+		paragraph := strings.Join(block, "\n")
+		if !tagRe.MatchString(paragraph) {
+			blocks = append(blocks, paragraph)
+			continue
+		}
+
+		rest, paragraphTags := ParseTags(paragraph)
+		if rest != "" {
+			blocks = append(blocks, paragraph)
+			continue
+		}
+		tags = tags.Add(paragraphTags...)
+	}
+
+	return strings.Join(blocks, "\n\n"), tags
+}
+
+// -----------------------------------------------------------------------------
+// commentWithTags
+//
+// Tags of tables are written as the first paragraph of the comment
+// -----------------------------------------------------------------------------
+func commentWithTags(comment string, tags DbTags) string {
+	if len(tags) == 0 {
+		return comment
+	}
+	if comment == "" {
+		return tags.String()
+	}
+	return tags.String() + "\n\n" + comment
+}
+
+// -----------------------------------------------------------------------------
+// commentWithTrailingTags
+//
+// Databases without a place for tags store them as a structured suffix of the
+// comment (its last paragraph), which is read back as tags by ExtractTags
+// -----------------------------------------------------------------------------
+func commentWithTrailingTags(comment string, tags DbTags) string {
+	if len(tags) == 0 {
+		return comment
+	}
+	if comment == "" {
+		return tags.String()
+	}
+	return comment + "\n\n" + tags.String()
+}
+
+// -----------------------------------------------------------------------------
+// ExtractTags
+//
+// Move tags found on comments of tables and fields to their tags
+// -----------------------------------------------------------------------------
+func (dbLayout *DbLayout) ExtractTags() {
+	for _, schemaLayout := range dbLayout.Schemas {
+		for _, tableLayout := range schemaLayout.Tables {
+			comment, tags := extractCommentTags(tableLayout.Comment)
+			tableLayout.Comment = comment
+			tableLayout.Tags = tableLayout.Tags.Add(tags...)
+
+			for _, field := range tableLayout.Fields {
+				comment, tags := extractCommentTags(field.Comment)
+				field.Comment = comment
+				field.Tags = field.Tags.Add(tags...)
+			}
+		}
+	}
+}
+
+// -----------------------------------------------------------------------------
+// DbTaggedItem
+//
+// Table or field (when Field is set) that has a tag
+// -----------------------------------------------------------------------------
+type DbTaggedItem struct {
+	Schema string
+	Table  string
+	Field  string
+	Tag    DbTag
+}
+
+// -----------------------------------------------------------------------------
+// String
+//
+// Returns schema.table.field, skipping empty parts
+// -----------------------------------------------------------------------------
+func (item DbTaggedItem) String() string {
+	parts := []string{}
+	for _, part := range []string{item.Schema, item.Table, item.Field} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// -----------------------------------------------------------------------------
+// FindTag
+//
+// Returns all tables and fields with given tag, in order
+// -----------------------------------------------------------------------------
+func (dbLayout *DbLayout) FindTag(name string) []DbTaggedItem {
+	name = strings.TrimPrefix(name, "@")
+	items := []DbTaggedItem{}
+
+	for _, schemaLayout := range dbLayout.Schemas {
+		for _, tableLayout := range schemaLayout.Tables {
+			if tag, ok := tableLayout.Tags.Get(name); ok {
+				items = append(items, DbTaggedItem{
+					Schema: schemaLayout.Name,
+					Table:  tableLayout.Name,
+					Tag:    tag,
+				})
+			}
+
+			for _, field := range tableLayout.Fields {
+				if tag, ok := field.Tags.Get(name); ok {
+					items = append(items, DbTaggedItem{
+						Schema: schemaLayout.Name,
+						Table:  tableLayout.Name,
+						Field:  field.Name,
+						Tag:    tag,
+					})
+				}
+			}
+		}
+	}
+
+	return items
+}
@@ -0,0 +1,275 @@
+// Copyright (C) 2021 Pau Sanchez
+package lib
+
+import (
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"text/template"
+)
+
+// Built-in templates used by the text and markdown formats. They can be used
+// as a starting point to write custom templates. The fields of each table are
+// rendered by the "fields" template, so the same layout is shared by the list
+// and table variants.
+
+const textTemplate = `
+{{- with .FrontMatter }}{{ . }}
+
+{{ end -}}
+# {{ .Name }} ({{ .Type }})
+
+{{ with .Comment }}{{ textComment . | wrap 0 }}
+
+{{ end -}}
+{{ with .Verbatim }}{{ . }}
+
+{{ end -}}
+{{ range .Schemas -}}
+{{ if .Name -}}
+## {{ .Name }}
+
+{{ with .Comment }}{{ textComment . | wrap 0 }}
+
+{{ end -}}
+{{ with .Verbatim }}{{ . }}
+
+{{ end -}}
+{{ end -}}
+{{ range .Sequences -}}
+- sequence {{ .Name }} [{{ .Definition }}]
+{{ with .Comment }}
+{{ textComment . | wrap 2 }}
+{{ end -}}
+{{ with .Verbatim }}
+{{ . }}
+{{ end }}
+{{ end -}}
+{{ range .Tables -}}
+### {{ .Name }}
+
+{{ with .Stats }}{{ with .String }}> {{ . }}
+
+{{ end }}{{ end -}}
+{{ with withTags .Comment .Tags }}{{ textComment . | wrap 0 }}
+
+{{ end -}}
+{{ with .Verbatim }}{{ . }}
+
+{{ end -}}
+{{ range .Fields -}}
+- {{ .Name }} [{{ typeString . }}{{ if .IsNullable }}?{{ end }}{{ with .Tags }} {{ . }}{{ end }}{{ with .Default }} default {{ . }}{{ end }}]
+{{ with .Comment }}
+{{ textComment . | wrap 2 }}
+{{ end -}}
+{{ with .Profile }}
+  > {{ . }}
+{{ end -}}
+{{ with .Verbatim }}
+{{ . }}
+{{ end }}
+{{ end -}}
+{{ range .Triggers -}}
+- trigger {{ .Name }} [{{ .Definition }}]
+{{ with .Comment }}
+{{ textComment . | wrap 2 }}
+{{ end -}}
+{{ with .Verbatim }}
+{{ . }}
+{{ end }}
+{{ end -}}
+{{ range .Policies -}}
+- policy {{ .Name }} [{{ .Definition }}]
+{{ with .Comment }}
+{{ textComment . | wrap 2 }}
+{{ end -}}
+{{ with .Verbatim }}
+{{ . }}
+{{ end }}
+{{ end -}}
+{{ end -}}
+{{ end -}}
+`
+
+const markdownLayoutTemplate = `
+{{- with .FrontMatter }}{{ . }}
+
+{{ end -}}
+# {{ markdownEscape .Name }} ({{ .Type }})
+
+{{ with .Comment }}{{ markdownComment . | wrap 0 }}
+
+{{ end -}}
+{{ with .Verbatim }}{{ . }}
+
+{{ end -}}
+{{ range .Schemas -}}
+{{ if .Name -}}
+## {{ markdownEscape .Name }}
+
+{{ with .Comment }}{{ markdownComment . | wrap 0 }}
+
+{{ end -}}
+{{ with .Verbatim }}{{ . }}
+
+{{ end -}}
+{{ end -}}
+{{ range .Sequences -}}
+- sequence {{ markdownEscape .Name }} [{{ markdownEscape .Definition }}]
+{{ with .Comment }}
+{{ markdownComment . | wrap 2 }}
+{{ end -}}
+{{ with .Verbatim }}
+{{ . }}
+{{ end }}
+{{ end -}}
+{{ range .Tables -}}
+### {{ markdownEscape .Name }}
+
+{{ with .Stats }}{{ with .String }}> {{ . }}
+
+{{ end }}{{ end -}}
+{{ with withTags .Comment .Tags }}{{ markdownComment . | wrap 0 }}
+
+{{ end -}}
+{{ with .Verbatim }}{{ . }}
+
+{{ end -}}
+{{ template "fields" . -}}
+{{ range .Triggers -}}
+- trigger {{ markdownEscape .Name }} [{{ markdownEscape .Definition }}]
+{{ with .Comment }}
+{{ markdownComment . | wrap 2 }}
+{{ end -}}
+{{ with .Verbatim }}
+{{ . }}
+{{ end }}
+{{ end -}}
+{{ range .Policies -}}
+- policy {{ markdownEscape .Name }} [{{ markdownEscape .Definition }}]
+{{ with .Comment }}
+{{ markdownComment . | wrap 2 }}
+{{ end -}}
+{{ with .Verbatim }}
+{{ . }}
+{{ end }}
+{{ end -}}
+{{ end -}}
+{{ end -}}
+`
+
+const markdownFieldListTemplate = `
+{{- define "fields" -}}
+{{ range .Fields -}}
+- {{ markdownEscape .Name }} [{{ typeString . | markdownEscape }}{{ if .IsNullable }}?{{ end }}{{ with .Tags }} {{ markdownEscape .String }}{{ end }}{{ with .Default }} default {{ markdownEscape . }}{{ end }}]
+{{ with .Comment }}
+{{ markdownComment . | wrap 2 }}
+{{ end -}}
+{{ with .Profile }}
+  > {{ . }}
+{{ end -}}
+{{ with .Verbatim }}
+{{ . }}
+{{ end }}
+{{ end -}}
+{{ end -}}
+`
+
+// Verbatim blocks of fields cannot be part of the table, so they are printed
+// right after it
+const markdownFieldTableTemplate = `
+{{- define "fields" -}}
+{{ if .Fields -}}
+| Name | Type | Nullable | PK | Default | Description |
+| --- | --- | --- | --- | --- | --- |
+{{ range .Fields -}}
+| {{ tableCell .Name }} | {{ typeString . | tableCell }}{{ with .Tags }} {{ tableCell .String }}{{ end }} | {{ yesNo .IsNullable }} | {{ yesNo .IsPrimaryKey }} | {{ tableCell .Default }} | {{ withProfile .Comment .Profile | tableCell }} |
+{{ end }}
+{{ range .Fields }}{{ with .Verbatim }}{{ . }}
+
+{{ end }}{{ end -}}
+{{ end -}}
+{{ end -}}
+`
+
+// -----------------------------------------------------------------------------
+// typeString
+//
+// Field type, including its length if any
+// -----------------------------------------------------------------------------
+func typeString(field *DbFieldLayout) string {
+	if field.Length > 0 {
+		return field.Type + strconv.Itoa(int(field.Length))
+	}
+	return field.Type
+}
+
+// -----------------------------------------------------------------------------
+// templateFuncs
+//
+// Helper functions available to all templates. Text is wrapped to the given
+// line length.
+// -----------------------------------------------------------------------------
+func templateFuncs(lineLength int) template.FuncMap {
+	return template.FuncMap{
+		"wrap": func(indent int, text string) string {
+			ww := NewWordWrap(lineLength, indent)
+			return ww.Wrap(text)
+		},
+		"textComment": func(comment string) string {
+			return escapeComment(comment, TextEscape)
+		},
+		"markdownEscape": MarkdownEscape,
+		"markdownComment": func(comment string) string {
+			return escapeComment(comment, MarkdownEscape)
+		},
+		"tableCell": func(text string) string {
+			return markdownTableCell(text, MarkdownEscape)
+		},
+		"dbmlEscape":  dbmlEscape,
+		"dbmlNote":    dbmlNote,
+		"typeString":  typeString,
+		"withTags":    commentWithTags,
+		"withProfile": commentWithProfile,
+		"yesNo":       yesNo,
+	}
+}
+
+// -----------------------------------------------------------------------------
+// NewDbLayoutTemplate
+//
+// Parse given template text, making all helper functions available
+// -----------------------------------------------------------------------------
+func NewDbLayoutTemplate(name string, text string, lineLength int) (*template.Template, error) {
+	return template.New(name).Funcs(templateFuncs(lineLength)).Parse(text)
+}
+
+// -----------------------------------------------------------------------------
+// NewDbLayoutTemplateFromFile
+// -----------------------------------------------------------------------------
+func NewDbLayoutTemplateFromFile(path string, lineLength int) (*template.Template, error) {
+	byteContents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewDbLayoutTemplate(filepath.Base(path), string(byteContents), lineLength)
+}
+
+// -----------------------------------------------------------------------------
+// PrintTemplate
+//
+// Render the layout through given template
+// -----------------------------------------------------------------------------
+func (dbLayout *DbLayout) PrintTemplate(out io.Writer, tmpl *template.Template) error {
+	return tmpl.Execute(out, dbLayout)
+}
+
+// -----------------------------------------------------------------------------
+// printBuiltinTemplate
+// -----------------------------------------------------------------------------
+func (dbLayout *DbLayout) printBuiltinTemplate(out io.Writer, lineLength int, text string) error {
+	tmpl := template.Must(NewDbLayoutTemplate("builtin", text, lineLength))
+	return dbLayout.PrintTemplate(out, tmpl)
+}
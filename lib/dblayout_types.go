@@ -16,37 +16,118 @@ type DbFieldLayout struct {
 	Length       uint32
 	Default      string
 	Comment      string
+	Tags         DbTags
+	Verbatim     string // user-authored content kept as it is
+	Profile      string // sampled values, empty unless profiled (-profile)
+}
+
+// DbTriggerLayout represents a trigger of a table
+type DbTriggerLayout struct {
+	Name     string
+	Timing   string   // BEFORE | AFTER | INSTEAD OF
+	Events   []string // INSERT | UPDATE | DELETE | TRUNCATE
+	Level    string   // ROW | STATEMENT, empty when unknown
+	Function string   // function or statement executed, empty when unknown
+	Comment  string
+	Verbatim string
+}
+
+// DbPolicyLayout represents a row level security policy of a table
+type DbPolicyLayout struct {
+	Name          string
+	IsRestrictive bool
+	Command       string   // ALL | SELECT | INSERT | UPDATE | DELETE
+	Roles         []string // empty when it applies to everyone
+	Using         string   // rows that can be read, empty when none
+	Check         string   // rows that can be written, empty when none
+	Comment       string
+	Verbatim      string
 }
 
 type DbTableLayout struct {
 	Name        string
 	Comment     string
+	Tags        DbTags
+	Verbatim    string
+	Stats       *DbTableStats // nil unless requested (-with-stats)
 	Fields      []*DbFieldLayout
 	FieldLookup map[string]*DbFieldLayout
+	Triggers    []*DbTriggerLayout
+	Policies    []*DbPolicyLayout
+}
+
+type DbEnumValueLayout struct {
+	Name    string
+	Comment string
+}
+
+type DbEnumLayout struct {
+	Name    string
+	Comment string
+	Values  []*DbEnumValueLayout
+}
+
+// DbSequenceLayout represents a sequence of a schema, which might be owned
+// by a field (e.g. serial columns)
+type DbSequenceLayout struct {
+	Name      string
+	Type      string // e.g. bigint, empty when unknown
+	Increment int64  // zero when unknown
+	OwnedBy   string // table.field, empty when not owned by any field
+	Comment   string
+	Verbatim  string
 }
 
 type DbSchemaLayout struct {
 	Name        string
 	Comment     string
+	Verbatim    string
+	Sequences   []*DbSequenceLayout
 	Tables      []*DbTableLayout
 	TableLookup map[string]*DbTableLayout
+	Enums       []*DbEnumLayout
+	EnumLookup  map[string]*DbEnumLayout
+}
+
+// DbRefLayout represents a relationship between the fields of two tables
+// (e.g. foreign keys), as written on DBML files
+type DbRefLayout struct {
+	Name       string
+	FromSchema string
+	FromTable  string
+	FromFields []string
+	Relation   string // > | < | - | <>
+	ToSchema   string
+	ToTable    string
+	ToFields   []string
+	Settings   string // raw settings, such as "delete: cascade"
 }
 
 type DbLayout struct {
 	Name         string
 	Type         string // DbTypeXXX
 	Comment      string
+	Verbatim     string
+	FrontMatter  string
 	Schemas      []*DbSchemaLayout
 	SchemaLookup map[string]*DbSchemaLayout
+	Refs         []*DbRefLayout
+	CatalogTags  []string // tags read from the database catalog, see DbDriver
 }
 
-// TODO: types + procedures + relationships
+// TODO: procedures
 
 const (
-	DbTypePostgres = "PostgreSQL"
-	DbTypeMysql    = "MySQL"
-	DbTypeMssql    = "MSSQL"
-	DbTypeSqlite   = "SQLite"
+	DbTypePostgres   = "PostgreSQL"
+	DbTypeCockroach  = "CockroachDB"
+	DbTypeYugabyte   = "YugabyteDB"
+	DbTypeClickhouse = "ClickHouse"
+	DbTypeMysql      = "MySQL"
+	DbTypeMariadb    = "MariaDB"
+	DbTypeMssql      = "MSSQL"
+	DbTypeOracle     = "Oracle"
+	DbTypeSqlite     = "SQLite"
+	DbTypeDuckdb     = "DuckDB"
 )
 
 const NoDbSchemaLayoutName = ""
@@ -59,8 +140,12 @@ func NewDbLayout(name string) DbLayout {
 	return DbLayout{
 		Name:         name,
 		Comment:      "",
+		Verbatim:     "",
+		FrontMatter:  "",
 		Schemas:      []*DbSchemaLayout{},
 		SchemaLookup: make(map[string]*DbSchemaLayout),
+		Refs:         []*DbRefLayout{},
+		CatalogTags:  []string{},
 	}
 }
 
@@ -71,8 +156,12 @@ func NewDbSchemaLayout(name string) DbSchemaLayout {
 	return DbSchemaLayout{
 		Name:        name,
 		Comment:     "",
+		Verbatim:    "",
+		Sequences:   []*DbSequenceLayout{},
 		Tables:      []*DbTableLayout{},
 		TableLookup: make(map[string]*DbTableLayout),
+		Enums:       []*DbEnumLayout{},
+		EnumLookup:  make(map[string]*DbEnumLayout),
 	}
 }
 
@@ -83,8 +172,13 @@ func NewDbTableLayout(name string) DbTableLayout {
 	return DbTableLayout{
 		Name:        name,
 		Comment:     "",
+		Tags:        DbTags{},
+		Verbatim:    "",
+		Stats:       nil,
 		Fields:      []*DbFieldLayout{},
 		FieldLookup: make(map[string]*DbFieldLayout),
+		Triggers:    []*DbTriggerLayout{},
+		Policies:    []*DbPolicyLayout{},
 	}
 }
 
@@ -101,6 +195,65 @@ func NewDbFieldLayout(name string) DbFieldLayout {
 		Length:       0,
 		Default:      "",
 		Comment:      "",
+		Tags:         DbTags{},
+		Verbatim:     "",
+		Profile:      "",
+	}
+}
+
+// -----------------------------------------------------------------------------
+// NewDbSequenceLayout
+// -----------------------------------------------------------------------------
+func NewDbSequenceLayout(name string) DbSequenceLayout {
+	return DbSequenceLayout{
+		Name:      name,
+		Type:      "",
+		Increment: 0,
+		OwnedBy:   "",
+		Comment:   "",
+		Verbatim:  "",
+	}
+}
+
+// -----------------------------------------------------------------------------
+// NewDbTriggerLayout
+// -----------------------------------------------------------------------------
+func NewDbTriggerLayout(name string) DbTriggerLayout {
+	return DbTriggerLayout{
+		Name:     name,
+		Timing:   "",
+		Events:   []string{},
+		Level:    "",
+		Function: "",
+		Comment:  "",
+		Verbatim: "",
+	}
+}
+
+// -----------------------------------------------------------------------------
+// NewDbPolicyLayout
+// -----------------------------------------------------------------------------
+func NewDbPolicyLayout(name string) DbPolicyLayout {
+	return DbPolicyLayout{
+		Name:          name,
+		IsRestrictive: false,
+		Command:       "",
+		Roles:         []string{},
+		Using:         "",
+		Check:         "",
+		Comment:       "",
+		Verbatim:      "",
+	}
+}
+
+// -----------------------------------------------------------------------------
+// NewDbEnumLayout
+// -----------------------------------------------------------------------------
+func NewDbEnumLayout(name string) DbEnumLayout {
+	return DbEnumLayout{
+		Name:    name,
+		Comment: "",
+		Values:  []*DbEnumValueLayout{},
 	}
 }
 
@@ -136,6 +289,21 @@ func (dbSchemaLayout *DbSchemaLayout) GetOrCreateTable(table string) *DbTableLay
 	return dbTableLayout
 }
 
+// -----------------------------------------------------------------------------
+// GetOrCreateEnum
+// -----------------------------------------------------------------------------
+func (dbSchemaLayout *DbSchemaLayout) GetOrCreateEnum(enum string) *DbEnumLayout {
+	if dbEnumLayout, ok := dbSchemaLayout.EnumLookup[enum]; ok {
+		return dbEnumLayout
+	}
+
+	newDbEnumLayout := NewDbEnumLayout(enum)
+	dbEnumLayout := &newDbEnumLayout
+	dbSchemaLayout.Enums = append(dbSchemaLayout.Enums, dbEnumLayout)
+	dbSchemaLayout.EnumLookup[enum] = dbEnumLayout
+	return dbEnumLayout
+}
+
 // -----------------------------------------------------------------------------
 // AddField
 // -----------------------------------------------------------------------------
@@ -181,6 +349,35 @@ func (dbLayout *DbLayout) GetTable(schema string, table string) *DbTableLayout {
 	return dbSchemaLayout.GetOrCreateTable(table)
 }
 
+// -----------------------------------------------------------------------------
+// LookupTable
+//
+// Like GetTable, but returns nil instead of creating the table (or its
+// schema) when it does not exist
+// -----------------------------------------------------------------------------
+func (dbLayout *DbLayout) LookupTable(schema string, table string) *DbTableLayout {
+	dbSchemaLayout, ok := dbLayout.SchemaLookup[schema]
+	if !ok {
+		return nil
+	}
+
+	return dbSchemaLayout.TableLookup[table]
+}
+
+// -----------------------------------------------------------------------------
+// LookupField
+//
+// Like GetField, but never creates the table (or its schema)
+// -----------------------------------------------------------------------------
+func (dbLayout *DbLayout) LookupField(schema string, table string, field string) *DbFieldLayout {
+	dbTableLayout := dbLayout.LookupTable(schema, table)
+	if dbTableLayout == nil {
+		return nil
+	}
+
+	return dbTableLayout.FieldLookup[field]
+}
+
 // -----------------------------------------------------------------------------
 // MergeFrom
 //
@@ -198,7 +395,7 @@ func (dbLayout *DbLayout) MergeFrom(
 	// insert in order
 	for _, schemaPtr := range dbLayout.Schemas {
 		if otherSchemaPtr, ok := otherLayout.SchemaLookup[schemaPtr.Name]; ok {
-			schemaPtr.MergeFrom(otherSchemaPtr, preserveComments, preserveMissing, false)
+			schemaPtr.MergeFrom(otherSchemaPtr, otherLayout.CatalogTags, preserveComments, preserveMissing, false)
 			mergedSchemas = append(mergedSchemas, schemaPtr)
 		} else if preserveMissing {
 			dupSchema := *schemaPtr
@@ -216,8 +413,20 @@ func (dbLayout *DbLayout) MergeFrom(
 
 	dbLayout.Name = otherLayout.Name
 	dbLayout.Type = otherLayout.Type
+	dbLayout.CatalogTags = otherLayout.CatalogTags
 	dbLayout.Schemas = append(mergedSchemas, deletedSchemas...)
 
+	// relationships only present on the other side are appended
+	refLookup := make(map[string]bool, len(dbLayout.Refs))
+	for _, refPtr := range dbLayout.Refs {
+		refLookup[refPtr.Key()] = true
+	}
+	for _, otherRefPtr := range otherLayout.Refs {
+		if !refLookup[otherRefPtr.Key()] {
+			dbLayout.Refs = append(dbLayout.Refs, otherRefPtr)
+		}
+	}
+
 	if !preserveComments || dbLayout.Comment == "" {
 		dbLayout.Comment = otherLayout.Comment
 	}
@@ -229,10 +438,12 @@ func (dbLayout *DbLayout) MergeFrom(
 // MergeFrom
 //
 // Merges tables and fields that exist on provided layout by preserving
-// the order from the current layout.
+// the order from the current layout. Tags named in catalogTags always come
+// from the provided layout.
 // -----------------------------------------------------------------------------
 func (dbSchemaLayout *DbSchemaLayout) MergeFrom(
 	otherSchemaLayout *DbSchemaLayout,
+	catalogTags []string,
 	preserveComments bool,
 	preserveMissing bool,
 	rebuildLookups bool,
@@ -243,7 +454,7 @@ func (dbSchemaLayout *DbSchemaLayout) MergeFrom(
 	// insert in order
 	for _, tablePtr := range dbSchemaLayout.Tables {
 		if otherTableLayout, ok := otherSchemaLayout.TableLookup[tablePtr.Name]; ok {
-			tablePtr.MergeFrom(otherTableLayout, preserveComments, preserveMissing, rebuildLookups)
+			tablePtr.MergeFrom(otherTableLayout, catalogTags, preserveComments, preserveMissing, rebuildLookups)
 			mergedTables = append(mergedTables, tablePtr)
 		} else if preserveMissing {
 			dupTable := *tablePtr
@@ -261,6 +472,19 @@ func (dbSchemaLayout *DbSchemaLayout) MergeFrom(
 
 	dbSchemaLayout.Name = otherSchemaLayout.Name
 	dbSchemaLayout.Tables = append(mergedTables, deletedTables...)
+	dbSchemaLayout.Sequences = mergeSequences(
+		dbSchemaLayout.Sequences,
+		otherSchemaLayout.Sequences,
+		preserveComments,
+		preserveMissing,
+	)
+
+	// enums only present on the other side are appended
+	for _, otherEnumPtr := range otherSchemaLayout.Enums {
+		if _, ok := dbSchemaLayout.EnumLookup[otherEnumPtr.Name]; !ok {
+			dbSchemaLayout.Enums = append(dbSchemaLayout.Enums, otherEnumPtr)
+		}
+	}
 
 	if !preserveComments || dbSchemaLayout.Comment == "" {
 		dbSchemaLayout.Comment = otherSchemaLayout.Comment
@@ -276,6 +500,7 @@ func (dbSchemaLayout *DbSchemaLayout) MergeFrom(
 // -----------------------------------------------------------------------------
 func (dbTableLayout *DbTableLayout) MergeFrom(
 	otherTableLayout *DbTableLayout,
+	catalogTags []string,
 	preserveComments bool,
 	preserveMissing bool,
 	rebuildLookups bool,
@@ -293,6 +518,19 @@ func (dbTableLayout *DbTableLayout) MergeFrom(
 			if preserveComments || otherFieldPtr.Comment == "" {
 				otherFieldPtr.Comment = fieldPtr.Comment
 			}
+
+			// tags follow the same rules as comments, except catalog ones
+			otherFieldPtr.Tags = mergeTags(fieldPtr.Tags, otherFieldPtr.Tags, catalogTags, preserveComments)
+
+			// user-authored blocks only exist on files, never on the database
+			if otherFieldPtr.Verbatim == "" {
+				otherFieldPtr.Verbatim = fieldPtr.Verbatim
+			}
+
+			// profiles are only replaced when sampled again
+			if otherFieldPtr.Profile == "" {
+				otherFieldPtr.Profile = fieldPtr.Profile
+			}
 		} else if preserveMissing {
 			dupField := *fieldPtr
 			dupField.Name = addDeletedPrefix(dupField.Name)
@@ -312,11 +550,30 @@ func (dbTableLayout *DbTableLayout) MergeFrom(
 
 	dbTableLayout.Name = otherTableLayout.Name
 	dbTableLayout.Fields = append(mergedFields, deletedFields...)
+	dbTableLayout.Triggers = mergeTriggers(
+		dbTableLayout.Triggers,
+		otherTableLayout.Triggers,
+		preserveComments,
+		preserveMissing,
+	)
+	dbTableLayout.Policies = mergePolicies(
+		dbTableLayout.Policies,
+		otherTableLayout.Policies,
+		preserveComments,
+		preserveMissing,
+	)
 
 	if !preserveComments || dbTableLayout.Comment == "" {
 		dbTableLayout.Comment = otherTableLayout.Comment
 	}
 
+	dbTableLayout.Tags = mergeTags(dbTableLayout.Tags, otherTableLayout.Tags, catalogTags, preserveComments)
+
+	// statistics change all the time, so they are only replaced when read
+	if otherTableLayout.Stats != nil {
+		dbTableLayout.Stats = otherTableLayout.Stats
+	}
+
 	if rebuildLookups {
 		dbTableLayout.RebuildLookups()
 	}
@@ -348,6 +605,11 @@ func (dbSchemaLayout *DbSchemaLayout) RebuildLookups() {
 
 		tablePtr.RebuildLookups()
 	}
+
+	dbSchemaLayout.EnumLookup = make(map[string]*DbEnumLayout, len(dbSchemaLayout.Enums))
+	for _, enumPtr := range dbSchemaLayout.Enums {
+		dbSchemaLayout.EnumLookup[enumPtr.Name] = enumPtr
+	}
 }
 
 // -----------------------------------------------------------------------------
@@ -380,6 +642,24 @@ func (a byFieldName) Len() int           { return len(a) }
 func (a byFieldName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a byFieldName) Less(i, j int) bool { return a[i].Name < a[j].Name }
 
+type bySequenceName []*DbSequenceLayout
+
+func (a bySequenceName) Len() int           { return len(a) }
+func (a bySequenceName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a bySequenceName) Less(i, j int) bool { return a[i].Name < a[j].Name }
+
+type byTriggerName []*DbTriggerLayout
+
+func (a byTriggerName) Len() int           { return len(a) }
+func (a byTriggerName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byTriggerName) Less(i, j int) bool { return a[i].Name < a[j].Name }
+
+type byPolicyName []*DbPolicyLayout
+
+func (a byPolicyName) Len() int           { return len(a) }
+func (a byPolicyName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byPolicyName) Less(i, j int) bool { return a[i].Name < a[j].Name }
+
 // -----------------------------------------------------------------------------
 // Sort
 //
@@ -399,6 +679,7 @@ func (dbLayout *DbLayout) Sort() {
 // Sort inner objects in lexicographical order
 // -----------------------------------------------------------------------------
 func (dbSchemaLayout *DbSchemaLayout) Sort() {
+	sort.Sort(bySequenceName(dbSchemaLayout.Sequences))
 	sort.Sort(byTableName(dbSchemaLayout.Tables))
 
 	for _, tablePtr := range dbSchemaLayout.Tables {
@@ -413,6 +694,21 @@ func (dbSchemaLayout *DbSchemaLayout) Sort() {
 // -----------------------------------------------------------------------------
 func (dbTableLayout *DbTableLayout) Sort() {
 	sort.Sort(byFieldName(dbTableLayout.Fields))
+	sort.Sort(byTriggerName(dbTableLayout.Triggers))
+	sort.Sort(byPolicyName(dbTableLayout.Policies))
+}
+
+// -----------------------------------------------------------------------------
+// Key
+//
+// Returns a string that uniquely identifies the relationship
+// -----------------------------------------------------------------------------
+func (dbRefLayout *DbRefLayout) Key() string {
+	return dbRefLayout.FromSchema + "." + dbRefLayout.FromTable +
+		".(" + strings.Join(dbRefLayout.FromFields, ",") + ")" +
+		dbRefLayout.Relation +
+		dbRefLayout.ToSchema + "." + dbRefLayout.ToTable +
+		".(" + strings.Join(dbRefLayout.ToFields, ",") + ")"
 }
 
 // -----------------------------------------------------------------------------
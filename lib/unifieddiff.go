@@ -0,0 +1,233 @@
+// Copyright (C) 2021 Pau Sanchez
+package lib
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Number of unchanged lines shown around each change
+const DiffContextLines = 3
+
+const (
+	DIFF_EQUAL  = ' '
+	DIFF_DELETE = '-'
+	DIFF_INSERT = '+'
+)
+
+// -----------------------------------------------------------------------------
+// diffLine
+// -----------------------------------------------------------------------------
+type diffLine struct {
+	Op   byte
+	Text string
+}
+
+// Marker printed after a last line without a new line, as diff does
+const DiffNoNewline = "\\ No newline at end of file"
+
+// -----------------------------------------------------------------------------
+// splitDiffLines
+//
+// Split text in lines, without the empty line after the trailing new line.
+// When the text does not end with a new line, its last line keeps a "\n" that
+// no other line can have, so it differs from the same line with a new line.
+// -----------------------------------------------------------------------------
+func splitDiffLines(text string) []string {
+	if text == "" {
+		return []string{}
+	}
+	if !strings.HasSuffix(text, "\n") {
+		lines := strings.Split(text, "\n")
+		lines[len(lines)-1] += "\n"
+		return lines
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// -----------------------------------------------------------------------------
+// diffLines
+//
+// Shortest edit script between a and b (Myers' algorithm), as a list of
+// equal, deleted and inserted lines in order
+// -----------------------------------------------------------------------------
+func diffLines(a []string, b []string) []diffLine {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+
+	// furthest x reached on each diagonal k, for each number of edits d
+	v := make([]int, 2*max+2)
+	trace := [][]int{}
+
+	found := false
+	for d := 0; d <= max && !found; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+
+		// only diagonals -d..d are needed to walk back from this step
+		trace = append(trace, append([]int{}, v[offset-d:offset+d+1]...))
+	}
+
+	// walk the trace backwards to rebuild the edit script
+	result := []diffLine{}
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		k := x - y
+
+		var prevK int
+		if d == 0 {
+			prevK = 0
+		} else if k == -d || (k != d && trace[d-1][k-1+d-1] < trace[d-1][k+1+d-1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+
+		prevX := 0
+		if d > 0 {
+			prevX = trace[d-1][prevK+d-1]
+		}
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			result = append(result, diffLine{DIFF_EQUAL, a[x]})
+		}
+
+		if d > 0 {
+			if x == prevX {
+				y--
+				result = append(result, diffLine{DIFF_INSERT, b[y]})
+			} else {
+				x--
+				result = append(result, diffLine{DIFF_DELETE, a[x]})
+			}
+		}
+	}
+
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+
+	return result
+}
+
+// -----------------------------------------------------------------------------
+// UnifiedDiff
+//
+// Returns the differences between two texts in unified format (as diff -u or
+// git diff), or an empty string when they are the same
+// -----------------------------------------------------------------------------
+func UnifiedDiff(fromName string, toName string, from string, to string) string {
+	if from == to {
+		return ""
+	}
+
+	lines := diffLines(splitDiffLines(from), splitDiffLines(to))
+
+	var buff strings.Builder
+	fmt.Fprintf(&buff, "--- %s\n", fromName)
+	fmt.Fprintf(&buff, "+++ %s\n", toName)
+
+	// line numbers (0-based) on each side where each diff line starts
+	fromLine := make([]int, len(lines)+1)
+	toLine := make([]int, len(lines)+1)
+	for i, line := range lines {
+		fromLine[i+1], toLine[i+1] = fromLine[i], toLine[i]
+		if line.Op != DIFF_INSERT {
+			fromLine[i+1]++
+		}
+		if line.Op != DIFF_DELETE {
+			toLine[i+1]++
+		}
+	}
+
+	for i := 0; i < len(lines); {
+		if lines[i].Op == DIFF_EQUAL {
+			i++
+			continue
+		}
+
+		// extend the hunk while changes are close enough to share context
+		start := i - DiffContextLines
+		if start < 0 {
+			start = 0
+		}
+
+		end := i
+		for end < len(lines) {
+			if lines[end].Op != DIFF_EQUAL {
+				end++
+				continue
+			}
+
+			next := end
+			for next < len(lines) && lines[next].Op == DIFF_EQUAL {
+				next++
+			}
+			if next == len(lines) || next-end > 2*DiffContextLines {
+				break
+			}
+			end = next
+		}
+
+		end += DiffContextLines
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		fmt.Fprintf(
+			&buff,
+			"@@ -%s +%s @@\n",
+			diffRange(fromLine[start], fromLine[end]-fromLine[start]),
+			diffRange(toLine[start], toLine[end]-toLine[start]),
+		)
+		for _, line := range lines[start:end] {
+			buff.WriteByte(line.Op)
+			buff.WriteString(line.Text)
+			if strings.HasSuffix(line.Text, "\n") {
+				buff.WriteString(DiffNoNewline)
+			}
+			buff.WriteByte('\n')
+		}
+
+		i = end
+	}
+
+	return buff.String()
+}
+
+// -----------------------------------------------------------------------------
+// diffRange
+//
+// Range of a hunk header, 1-based, with the count omitted when it is 1
+// -----------------------------------------------------------------------------
+func diffRange(start int, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
@@ -24,9 +24,36 @@ func NewWordWrap(maxLength int, indent int) WordWrap {
 // -----------------------------------------------------------------------------
 // Wrap
 //
-// Word wrap given text using the desired options when the class was initialized
+// Word wrap given text using the desired options when the class was initialized.
+// Only plain paragraphs are wrapped, lists and code blocks are just indented.
 // -----------------------------------------------------------------------------
 func (ww *WordWrap) Wrap(input string) string {
+	blocks := []string{}
+
+	for _, block := range splitCommentBlocks(strings.Split(input, "\n")) {
+		if !isVerbatimCommentBlock(block) {
+			blocks = append(blocks, ww.wrapParagraph(strings.Join(block, " ")))
+			continue
+		}
+
+		indented := make([]string, 0, len(block))
+		for _, line := range block {
+			if strings.TrimSpace(line) == "" {
+				indented = append(indented, "")
+			} else {
+				indented = append(indented, strings.Repeat(" ", ww.Indent)+line)
+			}
+		}
+		blocks = append(blocks, strings.Join(indented, "\n"))
+	}
+
+	return strings.Join(blocks, "\n\n")
+}
+
+// -----------------------------------------------------------------------------
+// wrapParagraph
+// -----------------------------------------------------------------------------
+func (ww *WordWrap) wrapParagraph(input string) string {
 	lines := make([]string, 0, 1+2*len(input)/int(ww.MaxLength))
 
 	space := " "
@@ -2,15 +2,54 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/pausan/syncdbdocs/lib"
 )
 
+// -----------------------------------------------------------------------------
+// exitIfInterrupted
+//
+// Exit without writing anything when the context has been cancelled (SIGINT
+// or SIGTERM) or has timed out
+// -----------------------------------------------------------------------------
+func exitIfInterrupted(ctx context.Context, timeout time.Duration) {
+	switch ctx.Err() {
+	case context.Canceled:
+		fmt.Fprintln(os.Stderr, "ERROR: interrupted, nothing has been written")
+		os.Exit(-9)
+	case context.DeadlineExceeded:
+		fmt.Fprintf(os.Stderr, "ERROR: timed out after %s, nothing has been written\n", timeout)
+		os.Exit(-9)
+	}
+}
+
+// -----------------------------------------------------------------------------
+// splitPatterns
+//
+// Split comma separated patterns, skipping empty ones
+// -----------------------------------------------------------------------------
+func splitPatterns(text string) []string {
+	patterns := []string{}
+	for _, pattern := range strings.Split(text, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
 func main() {
 	var dbhost string
 	var dbport uint
@@ -26,21 +65,49 @@ func main() {
 	var lineLength int
 	var dbCommentsFirst bool
 	var cleanDeletedItems bool
-	// TODO: var syncToDb bool
+	var strict bool
+	var splitBy string
+	var templateFile string
+	var listTag string
+	var syncToDb bool
+	var withStats bool
+	var profile string
+	var profileExclude string
+	var profileRows int
+	var profileTop int
+	var timeout time.Duration
+	var queryTimeout time.Duration
+	var verbose bool
+	var backup bool
+	var check bool
 
 	flag.StringVar(&dbhost, "h", "127.0.0.1", "Host you want to connect to")
 	flag.UintVar(&dbport, "p", 0, "Port on given host you want to connect to")
 	flag.StringVar(&dbuser, "u", "", "Username credentials (password should be set via DB_PASSWORD env var)")
 	flag.StringVar(&dbname, "d", "", "Database name you want to connect to")
-	flag.StringVar(&dbtype, "t", "auto", "Database type: auto | pg | mysql | mariadb | mssql | sqlite")
+	flag.StringVar(&dbtype, "t", "auto", "Database type: auto | "+strings.Join(lib.GetDbDriverNames(), " | "))
 	flag.StringVar(&inputFile, "i", "", "Use given input file to extend on")
 	flag.StringVar(&outputFile, "o", "", "Output file to generate")
 	flag.StringVar(&inputOutputFile, "io", "", "Read and write to the same file")
-	flag.StringVar(&format, "format", "", "Output format (text | markdown | dbml)")
+	flag.StringVar(&format, "format", "", "Output format (text | markdown | md-table | dbml | csv | tsv). By default it is guessed from the output file extension")
+	flag.StringVar(&templateFile, "template", "", "Render the output with given Go template file instead of the built-in formats")
 	flag.IntVar(&lineLength, "line-length", 80, "Set line length for the text/markdown representation")
 	flag.BoolVar(&dbCommentsFirst, "db-comments-first", false, "By default file comments are preserved. Enable this to override file comments with db comments.")
 	flag.BoolVar(&cleanDeletedItems, "clean", false, "By default existing schemas/tables/fields are preserved even if removed from database. With clean they will get effectively removed from the output")
-	// TODO: flag.BoolVar(&syncToDb, "sync-to-db", false, "Update database comments from markdown")
+	flag.StringVar(&splitBy, "split", "", "Write one file per schema or per table (schema | table) inside the output directory")
+	flag.StringVar(&listTag, "list-tag", "", "Instead of writing the documentation, list all tables and fields with given tag (e.g. pii)")
+	flag.BoolVar(&backup, "backup", false, "Keep the previous version of the output file(s) with .bak suffix")
+	flag.BoolVar(&check, "check", false, "Do not write anything, just print a diff and fail if the output file(s) are not up to date")
+	flag.BoolVar(&strict, "strict", false, "Fail if the input file has anything that cannot be parsed, instead of just warning about it")
+	flag.DurationVar(&timeout, "timeout", 0, "Give up if connecting and reading the database takes longer than this (e.g. 30s, 5m). No timeout by default")
+	flag.DurationVar(&queryTimeout, "query-timeout", 0, "Give up if a single query takes longer than this (e.g. 10s). No timeout by default")
+	flag.BoolVar(&verbose, "v", false, "Verbose mode, report progress on stderr")
+	flag.BoolVar(&syncToDb, "sync-to-db", false, "Update database comments and tags (pg | mssql | oracle) from the input file")
+	flag.BoolVar(&withStats, "with-stats", false, "Document table statistics too (estimated rows, size on disk and last time analyzed). Statistics in the input file are kept otherwise")
+	flag.StringVar(&profile, "profile", "", "Profile the values (distinct, nulls, min/max and top values) of the fields matching given comma separated patterns (e.g. public.order.status,public.user.*). Fields tagged @pii are never profiled")
+	flag.StringVar(&profileExclude, "profile-exclude", "", "Never profile the fields matching given comma separated patterns (e.g. public.user.password)")
+	flag.IntVar(&profileRows, "profile-rows", 10000, "Maximum number of rows sampled on each profiled table")
+	flag.IntVar(&profileTop, "profile-top", 5, "Maximum number of top values of each profiled field")
 
 	// dbhostEnv := os.Getenv("DB_HOST")
 	// dbportEnv := os.Getenv("DB_PORT")
@@ -50,7 +117,7 @@ func main() {
 	flag.Parse()
 
 	if dbname == "" {
-		fmt.Println("You should provide database name with -d flag")
+		fmt.Fprintln(os.Stderr, "You should provide database name with -d flag")
 		os.Exit(-1)
 	}
 
@@ -58,22 +125,58 @@ func main() {
 		dbuser = dbuserEnv
 	}
 
-	conn, err := lib.DbConnect(dbtype, dbhost, dbport, dbuser, dbpass, dbname)
+	// stop reading on SIGINT/SIGTERM, a second signal kills the process
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		signal.Stop(signals)
+		cancel()
+	}()
+
+	if check && syncToDb {
+		fmt.Fprintln(os.Stderr, "ERROR: -check cannot be used with -sync-to-db")
+		os.Exit(-1)
+	}
+
+	if check && outputFile == "" && inputOutputFile == "" {
+		fmt.Fprintln(os.Stderr, "ERROR: -check requires an output file (-o or -io)")
+		os.Exit(-1)
+	}
+
+	conn, err := lib.DbConnect(ctx, dbtype, dbhost, dbport, dbuser, dbpass, dbname)
 	if err != nil {
+		exitIfInterrupted(ctx, timeout)
+
 		connString := fmt.Sprintf("%s://%s:*****@%s:%d/%s", dbtype, dbuser, dbhost, dbport, dbname)
 		if conn != nil {
 			connString = strings.ReplaceAll(conn.GetConnectionString(), dbpass, "*****")
 		}
 
-		fmt.Println(err)
-		fmt.Println("ERROR: Cannot connect to the database: ", connString)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, "ERROR: Cannot connect to the database: ", connString)
 		os.Exit(-2)
 	}
 	defer conn.Close()
 
-	dbLayout, err := conn.GetLayout()
+	conn.SetQueryTimeout(queryTimeout)
+	conn.SetWithStats(withStats)
+	if verbose {
+		conn.SetProgress(os.Stderr)
+	}
+
+	dbLayout, err := conn.GetLayout(ctx)
 	if err != nil {
-		fmt.Println("ERROR: cannot create layout. ", err)
+		exitIfInterrupted(ctx, timeout)
+		fmt.Fprintln(os.Stderr, "ERROR: cannot create layout. ", err)
 		os.Exit(-3)
 	}
 
@@ -90,8 +193,9 @@ func main() {
 	}
 
 	// if output file exists and no input is specified, let's set input as
-	// the output so it will be rewritten but keeping the same order
-	if inputFile == "" && outputFile != "" {
+	// the output so it will be rewritten but keeping the same order (except
+	// for custom templates, which cannot be read back)
+	if inputFile == "" && outputFile != "" && templateFile == "" {
 		if f, _ := os.Open(outputFile); f != nil {
 			inputFile = outputFile
 			f.Close()
@@ -99,38 +203,220 @@ func main() {
 	}
 
 	if inputFile != "" {
-		fileLayout, err := lib.NewDbLayoutFromParsedFile(inputFile)
+		fileLayout, diagnostics, err := lib.NewDbLayoutFromParsedFile(inputFile)
 		if err != nil {
-			fmt.Printf("ERROR: cannot read input file %s: %s\n", inputFile, err)
+			fmt.Fprintf(os.Stderr, "ERROR: cannot read input file %s: %s\n", inputFile, err)
 			os.Exit(-4)
 		}
 
+		for _, diagnostic := range diagnostics {
+			fmt.Fprintln(os.Stderr, "WARNING:", diagnostic)
+		}
+
+		if strict && len(diagnostics) > 0 {
+			fmt.Fprintf(os.Stderr, "ERROR: input file %s has %d issue(s) and -strict is enabled\n", inputFile, len(diagnostics))
+			os.Exit(-6)
+		}
+
 		preserveFileComments := !dbCommentsFirst
 		preserveMissingItems := !cleanDeletedItems
 		fileLayout.MergeFrom(dbLayout, preserveFileComments, preserveMissingItems)
 		dbLayout = fileLayout
 	}
 
-	var outStream io.Writer = os.Stdout
-	if outputFile != "" {
-		ofile, err := os.Create(outputFile)
+	// profiled after merging, so tags of the input file (e.g. @pii) are honored
+	if profile != "" {
+		options := lib.NewDbProfileOptions()
+		options.Include = splitPatterns(profile)
+		options.Exclude = splitPatterns(profileExclude)
+		options.Rows = profileRows
+		options.Top = profileTop
+
+		warnings, err := conn.ProfileLayout(ctx, dbLayout, options)
 		if err != nil {
-			fmt.Printf("ERROR: cannot open output file %s: %s\n", outputFile, err)
-			os.Exit(-5)
+			exitIfInterrupted(ctx, timeout)
+			fmt.Fprintln(os.Stderr, "ERROR: cannot profile fields.", err)
+			os.Exit(-11)
+		}
+
+		for _, warning := range warnings {
+			fmt.Fprintln(os.Stderr, "WARNING:", warning)
+		}
+	}
+
+	// write comments and tags back, so they live both in docs and database
+	if syncToDb {
+		items, err := conn.SyncLayout(ctx, dbLayout)
+		if err != nil {
+			exitIfInterrupted(ctx, timeout)
+			fmt.Fprintln(os.Stderr, "ERROR: cannot update database.", err)
+			os.Exit(-8)
 		}
-		defer ofile.Close()
 
-		outStream = ofile
+		for _, item := range items {
+			fmt.Fprintln(os.Stderr, "Updated", item)
+		}
+		fmt.Fprintf(os.Stderr, "%d item(s) updated in database\n", len(items))
+	}
+
+	// report of tagged items, one per line: schema.table[.field] @tag[:value]
+	if listTag != "" {
+		for _, item := range dbLayout.FindTag(listTag) {
+			fmt.Printf("%s\t%s\n", item, item.Tag)
+		}
+		return
 	}
 
+	// guess format from the output file when not explicitly set
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(outputFile)) {
+		case ".md", ".markdown":
+			format = "markdown"
+		case ".dbml":
+			format = "dbml"
+		case ".csv":
+			format = "csv"
+		case ".tsv":
+			format = "tsv"
+		}
+
+		if format == "" && splitBy != "" {
+			format = "markdown"
+		}
+	}
+
+	extension := ".txt"
+	var printLayout func(*lib.DbLayout, io.Writer) error
+
 	switch strings.ToLower(format) {
 	case "md", "markdown":
-		dbLayout.PrintMarkdown(outStream, lineLength)
-	case "txt", "text", "plain":
-		dbLayout.PrintText(outStream, lineLength)
+		extension = ".md"
+		printLayout = func(layout *lib.DbLayout, out io.Writer) error {
+			return layout.PrintMarkdown(out, lineLength)
+		}
+	case "md-table", "markdown-table":
+		extension = ".md"
+		printLayout = func(layout *lib.DbLayout, out io.Writer) error {
+			return layout.PrintMarkdownTable(out, lineLength)
+		}
 	case "dbml":
-		dbLayout.PrintDbml(outStream, false)
+		extension = ".dbml"
+		printLayout = func(layout *lib.DbLayout, out io.Writer) error {
+			return layout.PrintDbml(out, true)
+		}
+	case "csv", "tsv":
+		separator := ','
+		extension = ".csv"
+		if strings.ToLower(format) == "tsv" {
+			separator = '\t'
+			extension = ".tsv"
+		}
+		printLayout = func(layout *lib.DbLayout, out io.Writer) error {
+			return layout.PrintCsv(out, separator)
+		}
 	default:
-		dbLayout.PrintText(outStream, lineLength)
+		printLayout = func(layout *lib.DbLayout, out io.Writer) error {
+			return layout.PrintText(out, lineLength)
+		}
+	}
+
+	// template errors are only found when rendering
+	writeErrorCode := -5
+	if templateFile != "" {
+		tmpl, err := lib.NewDbLayoutTemplateFromFile(templateFile, lineLength)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: cannot read template %s: %s\n", templateFile, err)
+			os.Exit(-7)
+		}
+
+		writeErrorCode = -7
+		printLayout = func(layout *lib.DbLayout, out io.Writer) error {
+			return layout.PrintTemplate(out, tmpl)
+		}
+	}
+
+	// same pipeline, but output is only compared with the existing file(s)
+	if check {
+		exitIfInterrupted(ctx, timeout)
+
+		var diff string
+		if splitBy != "" {
+			diff, err = dbLayout.DiffSplitFiles(outputFile, splitBy, extension, cleanDeletedItems, printLayout)
+		} else {
+			var output bytes.Buffer
+			err = printLayout(dbLayout, &output)
+			if err == nil {
+				var existing []byte
+				existing, err = ioutil.ReadFile(outputFile)
+				if os.IsNotExist(err) {
+					err = nil
+				}
+				diff = lib.UnifiedDiff(outputFile, outputFile, string(existing), output.String())
+			}
+		}
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: cannot check %s: %s\n", outputFile, err)
+			os.Exit(writeErrorCode)
+		}
+
+		if diff != "" {
+			fmt.Print(diff)
+			fmt.Fprintf(os.Stderr, "ERROR: %s is not up to date\n", outputFile)
+			os.Exit(-10)
+		}
+
+		fmt.Fprintf(os.Stderr, "%s is up to date\n", outputFile)
+		return
+	}
+
+	// split documentation is written to a directory instead
+	if splitBy != "" {
+		if outputFile == "" {
+			fmt.Fprintln(os.Stderr, "ERROR: -split requires an output directory (-o or -io)")
+			os.Exit(-5)
+		}
+
+		if extension != ".md" && extension != ".txt" {
+			fmt.Fprintf(os.Stderr, "ERROR: %s output cannot be split\n", format)
+			os.Exit(-5)
+		}
+
+		exitIfInterrupted(ctx, timeout)
+
+		err := dbLayout.WriteSplitFiles(outputFile, splitBy, extension, cleanDeletedItems, backup, printLayout)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: cannot write output directory %s: %s\n", outputFile, err)
+			os.Exit(writeErrorCode)
+		}
+		return
+	}
+
+	exitIfInterrupted(ctx, timeout)
+
+	// the output file is replaced only once everything has been written, so
+	// it is never left half written (nor lost with -io) on errors
+	if outputFile != "" {
+		err := lib.WriteFileAtomic(outputFile, backup, func(out io.Writer) error {
+			return printLayout(dbLayout, out)
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: cannot write output file %s: %s\n", outputFile, err)
+			os.Exit(writeErrorCode)
+		}
+		return
+	}
+
+	// render everything before writing to stdout, so errors are not mixed
+	// with a partial output
+	var output bytes.Buffer
+	if err := printLayout(dbLayout, &output); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: cannot write output: %s\n", err)
+		os.Exit(writeErrorCode)
+	}
+
+	if _, err := output.WriteTo(os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: cannot write output: %s\n", err)
+		os.Exit(-5)
 	}
 }
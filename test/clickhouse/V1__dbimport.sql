@@ -0,0 +1,14 @@
+CREATE DATABASE IF NOT EXISTS dbtest COMMENT 'Analytics events';
+
+CREATE TABLE IF NOT EXISTS dbtest.event
+(
+  date        Date COMMENT 'Day the event happened',
+  user_id     UInt64 COMMENT 'User that triggered the event',
+  name        LowCardinality(String),
+  referrer    Nullable(String) COMMENT 'Where the user came from, if known',
+  created_at  DateTime DEFAULT now()
+)
+ENGINE = MergeTree
+PARTITION BY toYYYYMM(date)
+ORDER BY (user_id, date)
+COMMENT 'Raw events sent by the application';
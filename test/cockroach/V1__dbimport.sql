@@ -0,0 +1,26 @@
+
+COMMENT ON DATABASE dbtest IS 'Database running on CockroachDB';
+
+CREATE SCHEMA syncdbtest;
+COMMENT ON SCHEMA syncdbtest IS 'Schema comments are read from pg_namespace';
+
+--------------------------------------------------------------------------------
+-- syncdbtest.user
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS syncdbtest.user (
+  id          UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  email       VARCHAR(128) NOT NULL,
+  name        STRING
+);
+
+COMMENT ON TABLE syncdbtest.user IS 'Users of the application';
+COMMENT ON COLUMN syncdbtest.user.email IS 'Email used to log in';
+
+--------------------------------------------------------------------------------
+-- syncdbtest.event_log: without primary key, so it has a hidden rowid column
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS syncdbtest.event_log (
+  message     STRING NOT NULL
+);
+
+COMMENT ON COLUMN syncdbtest.event_log.message IS 'Message logged';
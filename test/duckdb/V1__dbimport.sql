@@ -0,0 +1,23 @@
+CREATE SCHEMA analytics;
+
+CREATE TABLE analytics.customer (
+  id          INTEGER PRIMARY KEY,
+  email       VARCHAR NOT NULL UNIQUE,
+  created_at  TIMESTAMP DEFAULT current_timestamp
+);
+
+CREATE TABLE analytics.purchase (
+  id           INTEGER PRIMARY KEY,
+  customer_id  INTEGER NOT NULL REFERENCES analytics.customer(id),
+  amount       DECIMAL(10, 2)
+);
+
+CREATE VIEW analytics.purchase_total AS
+  SELECT customer_id, sum(amount) AS total
+    FROM analytics.purchase
+   GROUP BY customer_id;
+
+COMMENT ON TABLE analytics.customer IS 'Customers of the shop';
+COMMENT ON COLUMN analytics.customer.email IS 'Email used to log in';
+COMMENT ON TABLE analytics.purchase IS 'Purchases made by customers';
+COMMENT ON VIEW analytics.purchase_total IS 'Amount spent by each customer';
@@ -76,3 +76,52 @@ EXEC dbtest.sys.sp_addextendedproperty
   'schema', N'syncdbtest',
   'table', N'user',
   'column', N'password'
+GO
+
+CREATE TABLE syncdbtest.order_line(
+  id           INT IDENTITY(1,1) NOT NULL,
+  price        DECIMAL(10,2) NOT NULL,
+  quantity     INT NOT NULL DEFAULT 1,
+  total        AS (price * quantity) PERSISTED,
+  sku          NVARCHAR(32) NOT NULL,
+  sku_upper    AS UPPER(sku),
+  notes        NVARCHAR(MAX),
+  created_date DATETIME2(3) NOT NULL DEFAULT GETDATE()
+)
+GO
+
+CREATE VIEW syncdbtest.user_email AS
+  SELECT id, email FROM syncdbtest.[user]
+GO
+
+EXEC dbtest.sys.sp_addextendedproperty
+  'MS_Description', N'Lines of the orders, with generated totals',
+  'schema', N'syncdbtest',
+  'table', N'order_line'
+GO
+
+EXEC dbtest.sys.sp_addextendedproperty
+  'MS_Description', N'Email of each user',
+  'schema', N'syncdbtest',
+  'view', N'user_email'
+GO
+
+CREATE SEQUENCE syncdbtest.invoice_number AS INT
+  START WITH 1
+  INCREMENT BY 10
+GO
+
+EXEC dbtest.sys.sp_addextendedproperty
+  'MS_Description', N'Numbers of the invoices',
+  'schema', N'syncdbtest',
+  'sequence', N'invoice_number'
+GO
+
+CREATE TRIGGER syncdbtest.order_line_audit
+  ON syncdbtest.order_line
+  AFTER INSERT, UPDATE
+AS
+BEGIN
+  SET NOCOUNT ON;
+END
+GO
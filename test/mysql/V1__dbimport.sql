@@ -61,3 +61,18 @@ CREATE TABLE user (
 ALTER TABLE user COMMENT
   'This is the test comment that we are going to use for the user table, we can make it simpler, but this is long because we also want to test how good the algorithm of word-wrap works sorting things out; I believe it will work well, but we will see.';
 
+
+-- ------------------------------------------------------------------------------
+-- order_line: generated columns, charset/collation and row format
+-- ------------------------------------------------------------------------------
+CREATE TABLE order_line (
+  id          INT AUTO_INCREMENT PRIMARY KEY,
+  price       DECIMAL(10,2) NOT NULL,
+  quantity    INT NOT NULL DEFAULT 1,
+  total       DECIMAL(12,2) AS (price * quantity) STORED,
+  sku         VARCHAR(32) CHARACTER SET ascii COLLATE ascii_bin NOT NULL,
+  sku_prefix  VARCHAR(32) AS (UPPER(sku)) VIRTUAL
+) ROW_FORMAT=COMPACT COMMENT 'Lines of the orders, with generated totals';
+
+CREATE TRIGGER order_line_quantity BEFORE INSERT ON order_line
+  FOR EACH ROW SET NEW.quantity = GREATEST(NEW.quantity, 1);
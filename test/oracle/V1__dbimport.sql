@@ -0,0 +1,37 @@
+--------------------------------------------------------------------------------
+-- customer
+--------------------------------------------------------------------------------
+CREATE TABLE customer (
+  id          NUMBER(10) PRIMARY KEY,
+  email       VARCHAR2(128) NOT NULL UNIQUE,
+  name        NVARCHAR2(64),
+  created_at  TIMESTAMP DEFAULT SYSTIMESTAMP NOT NULL
+);
+
+COMMENT ON TABLE customer IS 'Customers of the legacy system';
+COMMENT ON COLUMN customer.email IS 'Email used to log in';
+
+--------------------------------------------------------------------------------
+-- invoice
+--------------------------------------------------------------------------------
+CREATE TABLE invoice (
+  id           NUMBER(10) PRIMARY KEY,
+  customer_id  NUMBER(10) NOT NULL,
+  amount       NUMBER(12, 2),
+  CONSTRAINT fk_invoice_customer FOREIGN KEY (customer_id) REFERENCES customer(id)
+);
+
+COMMENT ON TABLE invoice IS 'Invoices sent to customers';
+COMMENT ON COLUMN invoice.amount IS 'Total amount, taxes included';
+
+--------------------------------------------------------------------------------
+-- invoice_total
+--------------------------------------------------------------------------------
+CREATE VIEW invoice_total AS
+  SELECT customer_id, SUM(amount) AS total
+    FROM invoice
+   GROUP BY customer_id;
+
+COMMENT ON TABLE invoice_total IS 'Amount invoiced to each customer';
+
+EXIT;
@@ -119,3 +119,33 @@ COMMENT ON COLUMN syncdbtest.user.email IS
 
 COMMENT ON COLUMN syncdbtest.user.password IS
   'Password *** _ ## \\ \\`{}[]<>()#*+-_.!| **markdown** escape check';
+
+--------------------------------------------------------------------------------
+-- syncdbtest.order_line: identity, generated, collated and array columns
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS syncdbtest.order_line (
+  id        BIGINT GENERATED ALWAYS AS IDENTITY,
+  position  INTEGER GENERATED BY DEFAULT AS IDENTITY,
+  price     NUMERIC(10,2) NOT NULL,
+  quantity  INTEGER NOT NULL DEFAULT 1,
+  total     NUMERIC(12,2) GENERATED ALWAYS AS (price * quantity) STORED,
+  sku       VARCHAR(32) COLLATE "C" NOT NULL,
+  labels    TEXT[]
+);
+
+COMMENT ON TABLE syncdbtest.order_line IS
+  'Lines of the orders, with generated totals';
+
+COMMENT ON TRIGGER syncUpdatedDate ON syncdbtest.user IS
+  'Keeps updated_date up to date';
+
+--------------------------------------------------------------------------------
+-- row level security
+--------------------------------------------------------------------------------
+ALTER TABLE syncdbtest.order_line ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY order_line_positive ON syncdbtest.order_line
+  USING (quantity > 0);
+
+COMMENT ON POLICY order_line_positive ON syncdbtest.order_line IS
+  'Lines without quantity are hidden';
@@ -53,3 +53,18 @@ CREATE TABLE user (
   updated_date TIMESTAMP NOT NULL
 );
 
+
+CREATE TRIGGER user_updated_date AFTER UPDATE ON user
+  FOR EACH ROW
+BEGIN
+  UPDATE user SET updated_date = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+-- ------------------------------------------------------------------------------
+-- label: quoted type names and defaults with backticks, escaped on dbml
+-- ------------------------------------------------------------------------------
+CREATE TABLE label (
+  id    INTEGER PRIMARY KEY AUTOINCREMENT,
+  name  TEXT NOT NULL DEFAULT ('`' || 'untitled' || '`'),
+  color "rgb""hex" DEFAULT '#000000'
+);